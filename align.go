@@ -0,0 +1,63 @@
+package aof
+
+import (
+	"encoding/binary"
+)
+
+// Return the padding frame to write at "offset" so the next frame starts at a multiple
+// of Options.Alignment, or nil if it's aligned already or the alignment is disabled .
+// A padding frame has the framePadding flag set and a zero filled payload, so the scans
+// can skip it, it spans at least its own header, thus up to Alignment + 4 bytes .
+func (this *AOF) padding(offset int64) []byte {
+	align := int64(this.opts.Alignment)
+	if align <= 1 || offset % align == 0 {
+		return nil
+	}
+	gap := align - offset % align
+	for gap < frameHeaderSize {
+		gap += align
+	}
+	buf := make([]byte, gap)
+	binary.BigEndian.PutUint32(buf, uint32(gap - frameHeaderSize))
+	buf[frameFlagsOffset] = framePadding
+	return buf
+}
+
+// Write the encoded "frames" at once, each one preceded by the padding aligning it .
+// It returns the offset of each frame, the caller must hold the write lock .
+func (this *AOF) writeFrames(frames ...[]byte) ([]int64, error) {
+	return this.writeAligned(frames, nil)
+}
+
+// Write "bufs" at once like writeFrames, but only the ones whose "framed" entry is true are
+// aligned, all of them if "framed" is nil, the unframed records can't be told apart from
+// the padding so they're written as is . The caller must hold the write lock .
+func (this *AOF) writeAligned(bufs [][]byte, framed []bool) ([]int64, error) {
+	size := 0
+	for _, b := range bufs {
+		size += len(b)
+	}
+	if this.opts.Alignment > 1 {
+		// rotate first, so the padding is computed from the offsets it's written at
+		if err := this.rotate(int64(size + len(bufs) * (this.opts.Alignment + frameHeaderSize))); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, 0, size)
+	starts := make([]int64, len(bufs))
+	for i, b := range bufs {
+		if framed == nil || framed[i] {
+			buf = append(buf, this.padding(this.size + int64(len(buf))) ...)
+		}
+		starts[i] = int64(len(buf))
+		buf = append(buf, b ...)
+	}
+	offset, err := this.write(buf)
+	if err != nil {
+		return nil, err
+	}
+	for i := range starts {
+		starts[i] += offset
+	}
+	return starts, nil
+}
@@ -2,133 +2,1060 @@
 package aof
 
 import (
-	"encoding/hex"
 	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
 	"sync"
 	"fmt"
 	"os"
 	"io"
+	"math"
+	"path/filepath"
+	"time"
 )
 
+// Returned by internal callbacks to stop an iteration early .
+var errStop = errors.New(`aof: stop`)
+
+// Returned by the methods of a closed AOF, it's os.ErrClosed so errors.Is matches both .
+var ErrClosed = os.ErrClosed
+
+// Returned, wrapped with the details, when an id, an offset or an index points
+// outside of the datafile, or to a segment that doesn't exist .
+var ErrOutOfRange = errors.New(`aof: out of range`)
+
+// Returned, wrapped with the details, by ParsePosition and the methods taking
+// an id when it's malformed .
+var ErrBadID = errors.New(`aof: invalid id`)
+
+// Returned by PutIf when the datafile isn't at the expected size anymore .
+var ErrConflict = errors.New(`aof: datafile size changed`)
+
+// Returned by the write methods of an AOF opened with OpenReadOnly .
+var ErrReadOnly = errors.New(`aof: read only`)
+
+// Returned by Open when another process holds the lock of the datafile .
+var ErrLocked = errors.New(`aof: datafile is locked by another process`)
+
+// Returned by Healthy when the path of the datafile no longer leads to the open file .
+var ErrFileReplaced = errors.New(`aof: datafile was deleted or replaced`)
+
 // Our AOF struct .
 type AOF struct {
-	file	*os.File
+	file	datafile
+	path	string
+	mode	os.FileMode
 	size	int64
+	segment	int
+	segments	[]segment
+	closed	bool
+	readOnly	bool
+	temp	bool
+	unlocked	bool
+	opts	Options
+	done	chan struct{}
+	synced	chan struct{}
+	flusher	sync.WaitGroup
+	flushErr	error
+	rangeSynced	int64
+	hooks	hooks
+	metrics	metrics
+	index	[]Position
+	indexed	int
+	base	int64
+	flags	byte
+	deleted	map[Position]struct{}
+	aead	cipher.AEAD
+	mapping	[]byte
+	cache	*readCache
+	dedup	map[[sha256.Size]byte]Position
+	dedupLock	sync.Mutex
+	drainLock	sync.Mutex
+	queue	chan groupWrite
+	committed	chan struct{}
 	sync.RWMutex
 }
 
 // Open an AOF datafile .
 func Open(filename string, mode os.FileMode) (this *AOF, err error) {
+	return OpenWithOptions(filename, mode, Options{})
+}
+
+// Open an AOF datafile using the specified options .
+func OpenWithOptions(filename string, mode os.FileMode, opts Options) (this *AOF, err error) {
 	this = new(AOF)
-	this.file, err = os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, mode)
+	this.opts = opts
+	this.path = filename
+	this.mode = mode
+	this.done = make(chan struct{})
+	this.cache = newReadCache(opts.ReadCacheBytes)
+	if opts.EncryptionKey != nil {
+		if this.aead, err = newAEAD(opts.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+	var file *os.File
+	if opts.MaxSegmentBytes > 0 {
+		file, err = this.openSegments(mode)
+	} else if file, err = os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, mode); err != nil {
+		err = fmt.Errorf(`aof: opening the datafile: %w`, err)
+	}
+	if err == nil {
+		if err = lockFile(file, true); err != nil {
+			file.Close()
+		}
+	}
 	if err != nil {
+		for _, seg := range this.segments {
+			seg.file.Close()
+		}
 		return nil, err
 	}
+	this.file = file
 	finfo, err := this.file.Stat()
 	if err != nil {
 		this.Close()
 		return nil, err
 	}
 	this.size = finfo.Size()
+	if err := this.openHeader(); err != nil {
+		this.Close()
+		return nil, err
+	}
+	if opts.PublishExpvar {
+		publish(this)
+	}
+	if opts.MaxBytes > 0 && opts.MaxSegmentBytes <= 0 {
+		this.Close()
+		return nil, fmt.Errorf(`aof: MaxBytes requires MaxSegmentBytes`)
+	}
+	if opts.Alignment < 0 || int64(opts.Alignment) > MaxFrameSize {
+		this.Close()
+		return nil, fmt.Errorf(`aof: alignment %d isn't in [0, %d]`, opts.Alignment, MaxFrameSize)
+	}
+	if opts.BuildIndex {
+		this.buildIndex()
+	}
+	if opts.Tombstones {
+		this.loadTombstones()
+	}
+	if opts.Dedup {
+		this.loadDedup()
+	}
+	this.grew()
+	if opts.Sync.interval > 0 {
+		this.synced = make(chan struct{}, 1)
+		this.flusher.Add(1)
+		go this.flush(opts.Sync.interval)
+	}
+	if opts.GroupCommit > 0 {
+		this.startGroupCommit()
+	}
+	return this, nil
+}
+
+// Open an AOF datafile for reading only, writes return ErrReadOnly .
+// It doesn't require write access to the file, so it suits the tools
+// that only analyze or tail a log written by another process . It takes no lock,
+// a shared one would conflict with the exclusive lock of the writer, so it can
+// read while the writer appends, from RefreshSize on to see the new records .
+func OpenReadOnly(filename string) (this *AOF, err error) {
+	this = new(AOF)
+	this.path = filename
+	this.readOnly = true
+	this.unlocked = true
+	this.done = make(chan struct{})
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf(`aof: opening the datafile: %w`, err)
+	}
+	this.file = file
+	finfo, err := this.file.Stat()
+	if err != nil {
+		this.Close()
+		return nil, err
+	}
+	this.size = finfo.Size()
+	if err := this.openHeader(); err != nil {
+		this.Close()
+		return nil, err
+	}
 	return this, nil
 }
 
+// Wrap an already open file, inherited from systemd or created without a path for example,
+// so it's used as is without being reopened nor locked, and closed by Close .
+// The file is appended to from its current size, so it must be open for reading and writing .
+// The AOF has no path in this mode, so Path is empty and Reopen, Compact, Healthy and
+// the other methods working by path fail .
+func NewFromFile(f *os.File) (*AOF, error) {
+	finfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	this := new(AOF)
+	this.file = f
+	this.size = finfo.Size()
+	this.done = make(chan struct{})
+	this.unlocked = true
+	if _, err := f.Seek(this.size, io.SeekStart); err != nil && finfo.Mode().IsRegular() {
+		return nil, err
+	}
+	if err := this.openHeader(); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+// Open an AOF over a new temp file named after "pattern" like os.CreateTemp does,
+// the file is deleted by Close, which suits the tests and the short lived pipelines .
+func OpenTemp(pattern string) (*AOF, error) {
+	tmp, err := os.CreateTemp(``, pattern)
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	this, err := Open(tmp.Name(), 0600)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	this.temp = true
+	return this, nil
+}
+
+// Return an error if the AOF can't be written to, the caller must hold the lock .
+func (this *AOF) writable() error {
+	if this.closed {
+		return ErrClosed
+	}
+	if this.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// Fsync the datafile every "d" till the AOF is closed, then one last time so
+// Close doesn't return before the pending writes are durable .
+// The write lock is only held to pick the file, not during the fsync itself .
+func (this *AOF) flush(d time.Duration) {
+	defer this.flusher.Done()
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this.done:
+			this.flushErr = this.syncFile(this.file)
+			return
+		case <-this.synced:
+			ticker.Reset(d)
+		case <-ticker.C:
+			this.Lock()
+			file, closed := this.file, this.closed
+			this.Unlock()
+			if ! closed {
+				this.syncFile(file)
+			}
+		}
+	}
+}
+
 // Write from an io.Reader .
-// It returns the id 'pointer' of the inserted data and error if any .
+// It returns the id 'pointer' of the inserted data and error if any,
+// an empty reader is stored as a zero length record and still gets a valid id,
+// which Get returns an empty reader for, so records may serve as presence markers .
 func (this *AOF) Put(src io.Reader) (string, error) {
+	p, err := this.PutP(src)
+	if err != nil {
+		return ``, err
+	}
+	return p.String(), nil
+}
+
+// Write the concatenation of "srcs" as a single record, under a single lock and a single sync .
+// It returns the id 'pointer' spanning the combined data, if any of the readers fails
+// the datafile is truncated back so no partial record remains .
+func (this *AOF) PutMulti(srcs ...io.Reader) (string, error) {
+	return this.Put(io.MultiReader(srcs ...))
+}
+
+// Write from an io.Reader .
+// It returns the Position of the inserted data and error if any .
+func (this *AOF) PutP(src io.Reader) (Position, error) {
+	if this.transforms() || this.opts.Dedup {
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return Position{}, err
+		}
+		return this.PutBytesP(data)
+	}
+	p, err := this.putReader(src)
+	if err != nil {
+		return Position{}, err
+	}
+	this.notify(p)
+	return p, nil
+}
+
+// Copy "src" to the datafile under the write lock, read in memory first with Options.GroupCommit .
+func (this *AOF) putReader(src io.Reader) (Position, error) {
+	if this.opts.GroupCommit > 0 {
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return Position{}, err
+		}
+		return this.putBytes(data)
+	}
 	this.Lock()
 	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return Position{}, err
+	}
+	// the length is unknown, so a new segment is started once the active one is full
+	if err := this.rotate(1); err != nil {
+		return Position{}, err
+	}
 	offset := this.size
 	length, err := io.Copy(this.file, src)
-	if length == 0 && err != nil {
-		return ``, err
+	this.metrics.written.Add(length)
+	if err == nil {
+		err = this.commit(offset + length)
+	}
+	if err != nil {
+		// drop whatever got written so no half-written record survives
+		this.truncate(offset)
+		return Position{}, err
 	}
-	if err = this.file.Sync(); err != nil {
+	this.size += int64(length)
+	this.grew()
+	return this.position(offset, length), nil
+}
+
+// Write a byte slice directly without the io.Reader overhead .
+// It returns the id 'pointer' of the inserted data and error if any,
+// an empty slice is stored as a zero length record and still gets a valid id .
+func (this *AOF) PutBytes(data []byte) (string, error) {
+	p, err := this.PutBytesP(data)
+	if err != nil {
 		return ``, err
 	}
+	return p.String(), nil
+}
+
+// Write a byte slice like PutBytes, but return the Position of the inserted data,
+// whose Length is the number of stored bytes, instead of its id .
+func (this *AOF) PutBytesP(data []byte) (Position, error) {
+	if this.opts.Dedup {
+		return this.putDedup(data)
+	}
+	if this.transforms() {
+		_, p, err := this.putFrame(data, 0)
+		return p, err
+	}
+	p, err := this.putBytes(data)
+	if err != nil {
+		return Position{}, err
+	}
+	this.notify(p)
+	return p, nil
+}
+
+// Write "data" like PutBytesP, but only if the datafile is still at "expectedSize", the
+// Size the caller observed, otherwise nothing is written and ErrConflict is returned .
+// It's compare and append, a building block of the leader election logs and of the exactly
+// once producers . It only guards against the writers of this AOF and, since the datafile is
+// locked, of the other processes using this package, not against arbitrary external writers .
+// In segmented mode the size is the one of the active segment, which a rotation resets .
+func (this *AOF) PutIf(expectedSize int64, data []byte) (Position, error) {
+	buf, length := data, int64(len(data))
+	if this.transforms() {
+		stored, flags, err := this.encodeRecord(data, 0)
+		if err != nil {
+			return Position{}, err
+		}
+		if buf, err = encodeFrame(stored, frame{flags: flags, ts: time.Now().UnixNano()}); err != nil {
+			return Position{}, err
+		}
+		length = int64(len(stored))
+	}
+	p, err := this.putIf(expectedSize, buf, length)
+	if err != nil {
+		return Position{}, err
+	}
+	this.notify(p)
+	return p, nil
+}
+
+// Write "buf" whose last "length" bytes are the payload if the size is "expectedSize" .
+func (this *AOF) putIf(expectedSize int64, buf []byte, length int64) (Position, error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return Position{}, err
+	}
+	if this.size != expectedSize {
+		return Position{}, fmt.Errorf(`%w: expected %d, it's %d`, ErrConflict, expectedSize, this.size)
+	}
+	offsets, err := this.writeAligned([][]byte{buf}, []bool{this.transforms()})
+	if err != nil {
+		return Position{}, err
+	}
+	p := this.position(offsets[0] + int64(len(buf)) - length, length)
+	if this.index != nil && this.transforms() {
+		this.indexRecord(p)
+	}
+	return p, nil
+}
+
+// Write "data" to the datafile under the write lock .
+func (this *AOF) putBytes(data []byte) (Position, error) {
+	if this.opts.GroupCommit > 0 {
+		_, p, err := this.enqueue(data, int64(len(data)), false)
+		return p, err
+	}
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return Position{}, err
+	}
+	offset, err := this.write(data)
+	if err != nil {
+		return Position{}, err
+	}
+	return this.position(offset, int64(len(data))), nil
+}
+
+// Append "data" to the datafile and sync it according to the sync policy .
+// It returns the offset "data" was written at, the caller must hold the write lock .
+func (this *AOF) write(data []byte) (int64, error) {
+	if err := this.rotate(int64(len(data))); err != nil {
+		return 0, err
+	}
+	offset := this.size
+	length, err := this.file.Write(data)
+	this.metrics.written.Add(int64(length))
+	if err == nil {
+		err = this.commit(offset + int64(length))
+	}
+	if err != nil {
+		this.truncate(offset)
+		return 0, err
+	}
 	this.size += int64(length)
-	return hex.EncodeToString([]byte(fmt.Sprintf(`%d:%d`, offset, length))), nil
+	this.grew()
+	return offset, nil
+}
+
+// Fsync the datafile and count it .
+func (this *AOF) fsync() error {
+	return this.syncTo(this.size)
+}
+
+// Fsync the datafile written up to "end", only the range written since the previous
+// sync with Options.UseSyncRange, the caller must hold the write lock .
+func (this *AOF) syncTo(end int64) error {
+	file, ok := this.file.(*os.File)
+	if ! this.opts.UseSyncRange || ! ok || this.rangeSynced > end {
+		err := this.syncFile(this.file)
+		if err == nil {
+			this.rangeSynced = end
+		}
+		return err
+	}
+	this.metrics.fsyncs.Add(1)
+	offset := this.rangeSynced
+	err := this.within(func() error {
+		return syncRange(file, offset, end - offset)
+	})
+	if err == ErrTimeout {
+		return err
+	}
+	if err != nil {
+		// the kernel may not support it, the full sync is always right
+		err = this.syncFile(this.file)
+	}
+	if err == nil {
+		this.rangeSynced = end
+	}
+	return err
+}
+
+// Keep up with the datafile growth, the caller must hold the write lock .
+func (this *AOF) grew() {
+	this.remap()
+	this.trim()
+}
+
+// Sync the data written up to "end" according to the sync policy .
+func (this *AOF) commit(end int64) error {
+	if ! this.opts.Sync.always() {
+		return nil
+	}
+	return this.syncTo(end)
+}
+
+// Flush the written data to stable storage now .
+// With SyncInterval this also restarts the background interval .
+func (this *AOF) Sync() error {
+	this.Lock()
+	defer this.Unlock()
+	if this.closed {
+		return ErrClosed
+	}
+	if err := this.fsync(); err != nil {
+		return err
+	}
+	if this.synced != nil {
+		select {
+		case this.synced <- struct{}{}:
+		default:
+		}
+	}
+	return nil
 }
 
 // Read the data of the pointer "id" .
+// It returns nil if the AOF is closed or the id is invalid, use GetReader to know why .
 func (this *AOF) Get(id string) *io.SectionReader {
+	r, err := this.GetReader(id)
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// Read the data of the pointer "id" .
+// It returns an error if the id is malformed or points outside of the datafile .
+func (this *AOF) GetReader(id string) (*io.SectionReader, error) {
+	p, err := ParsePosition(id)
+	if err != nil {
+		return nil, err
+	}
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return nil, ErrClosed
+	}
+	file, size, err := this.segmentFile(p.Segment)
+	if err != nil {
+		return nil, err
+	}
+	if p.Length > size || p.Offset > size - p.Length {
+		return nil, fmt.Errorf(`%w: id %q: %d:%d exceeds size %d`, ErrOutOfRange, id, p.Offset, p.Length, size)
+	}
+	this.metrics.reads.Add(1)
+	if ! this.cache.fits(p.Length) {
+		return this.section(file, p)
+	}
+	key := cacheKey{pos: p}
+	entry, ok := this.cache.get(key)
+	if ok {
+		this.metrics.cacheHits.Add(1)
+	} else {
+		this.metrics.cacheMisses.Add(1)
+		if entry.data, err = this.readRecord(file, p); err != nil {
+			return nil, err
+		}
+		this.cache.put(key, 0, entry.data)
+	}
+	return io.NewSectionReader(bytes.NewReader(entry.data), 0, int64(len(entry.data))), nil
+}
+
+// Read the whole record of the pointer "id" into a new slice the caller owns, which is the
+// common way to read a record, keep GetReader to stream the large ones instead of copying them .
+// It returns ErrBadID if "id" is malformed, ErrOutOfRange if it's outside of the datafile
+// and the error of the read if any .
+func (this *AOF) GetAll(id string) ([]byte, error) {
+	r, err := this.GetReader(id)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Open the record of the pointer "id" as an io.ReadSeeker bounded to it, for the
+// libraries expecting a seekable input, like archive/zip or the media decoders .
+// It returns ErrBadID if "id" is malformed and ErrOutOfRange if it's outside of the datafile .
+func (this *AOF) OpenRecord(id string) (io.ReadSeeker, error) {
+	r, err := this.GetReader(id)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Stream the record of the pointer "id" to "w" without buffering it whole,
+// it returns the number of bytes written . The read lock is held during the copy,
+// so the record can't be compacted away mid-stream, a slow "w" delays the writers .
+func (this *AOF) WriteRecordTo(id string, w io.Writer) (int64, error) {
+	p, err := ParsePosition(id)
+	if err != nil {
+		return 0, err
+	}
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return 0, ErrClosed
+	}
+	file, size, err := this.segmentFile(p.Segment)
+	if err != nil {
+		return 0, err
+	}
+	if p.Length > size || p.Offset > size - p.Length {
+		return 0, fmt.Errorf(`%w: id %q: %d:%d exceeds size %d`, ErrOutOfRange, id, p.Offset, p.Length, size)
+	}
+	var src io.ReaderAt = file
+	if file == this.file {
+		src = readerAtFunc(this.readAt)
+	}
+	r, err := this.sectionOf(src, file, p)
+	if err != nil {
+		return 0, err
+	}
+	this.metrics.reads.Add(1)
+	return io.Copy(w, r)
+}
+
+// Read the data of many ids at once under a single read lock, so they're consistent
+// with each other and cheaper to get than by as many Get calls .
+// On the first malformed or out of range id it returns the error along with the data read so far .
+func (this *AOF) BatchGet(ids []string) ([][]byte, error) {
 	this.RLock()
 	defer this.RUnlock()
-	var offset, length int64
-	idBytes, e := hex.DecodeString(id)
-	if e != nil {
+	if this.closed {
+		return nil, ErrClosed
+	}
+	results := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		p, err := ParsePosition(id)
+		if err != nil {
+			return results, err
+		}
+		file, size, err := this.segmentFile(p.Segment)
+		if err != nil {
+			return results, err
+		}
+		if p.Length > size || p.Offset > size - p.Length {
+			return results, fmt.Errorf(`%w: id %q: %d:%d exceeds size %d`, ErrOutOfRange, id, p.Offset, p.Length, size)
+		}
+		this.metrics.reads.Add(1)
+		data, err := this.readRecord(file, p)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, data)
+	}
+	return results, nil
+}
+
+// Whether "id" is well formed and points to a range inside the datafile .
+func (this *AOF) Exists(id string) bool {
+	_, err := this.GetReader(id)
+	return err == nil
+}
+
+// Read the data at the Position "p" .
+// It returns nil if the AOF is closed or the segment doesn't exist .
+func (this *AOF) GetP(p Position) *io.SectionReader {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return nil
+	}
+	file, _, err := this.segmentFile(p.Segment)
+	if err != nil {
 		return nil
 	}
-	id = string(idBytes)
-	fmt.Sscanf(id, `%d:%d`, &offset, &length)
- 	return io.NewSectionReader(this.file, offset, length)
+	this.metrics.reads.Add(1)
+	r, err := this.section(file, p)
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// Read len(p) bytes starting at offset "off" of the datafile, so AOF is an io.ReaderAt .
+// Reads are bounded by the current size and return io.EOF when they reach it .
+func (this *AOF) ReadAt(p []byte, off int64) (int, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if off < 0 {
+		return 0, fmt.Errorf(`%w: negative offset %d`, ErrOutOfRange, off)
+	}
+	this.metrics.reads.Add(1)
+	return this.readAt(p, off)
+}
+
+// Write a consistent snapshot of the records of the datafile to "w", so AOF is an io.WriterTo .
+// The copy is bounded by the size at the time of the call, so the concurrent
+// appends are excluded, and the writers wait till it ends . The header isn't
+// part of it, so the backup can be loaded by ReadFrom into any datafile .
+func (this *AOF) WriteTo(w io.Writer) (int64, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return 0, ErrClosed
+	}
+	return io.Copy(w, io.NewSectionReader(this.file, this.base, this.size - this.base))
+}
+
+// Load a backup from "r" into the empty datafile, so AOF is an io.ReaderFrom .
+// The copied data is synced before returning, on failure the datafile is emptied again .
+func (this *AOF) ReadFrom(r io.Reader) (int64, error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return 0, err
+	}
+	if this.size != this.base {
+		return 0, fmt.Errorf(`aof: can't restore into a non empty datafile`)
+	}
+	n, err := io.Copy(this.file, r)
+	if err == nil {
+		err = this.fsync()
+	}
+	if err != nil {
+		this.truncate(this.base)
+		return 0, err
+	}
+	this.size += n
+	this.grew()
+	if this.index != nil {
+		this.buildIndex()
+	}
+	return n, nil
 }
 
 // Scan the datafile using a custom separator and function.
 // The provided function has two params, data and whether we at the end or not .
-// This function will lock the whole file till it ends .
+// The separator is detected at any offset, records are passed without it,
+// and consecutive separators delimit empty records which are passed too .
+// This function will hold the read lock till it ends .
 func (this *AOF) Scan(sep []byte, fn func(data []byte, atEOF bool) bool) {
-	this.Lock()
-	defer this.Unlock()
-	this.file.Seek(0, 0)
-	data := []byte{}
-	for {
-		tmp := make([]byte, len(sep))
-		n, e := this.file.Read(tmp)
-		if n > 0 {
-			data = append(data, tmp[0:n] ...)
-		}
-		if e != nil || n == 0 {
-			if len(data) > 0 {
-				fn(bytes.Trim(data, string(sep)), true)
-			}
-			break
+	this.ScanErr(sep, func(data []byte, atEOF bool) error {
+		if ! fn(data, atEOF) {
+			return errStop
 		}
-		if bytes.Equal(sep, tmp) {
-			if ! fn(bytes.Trim(data, string(sep)), false) {
-				break
-			}
-			data = []byte{}
+		return nil
+	})
+}
+
+// Scan the datafile like Scan, but a non-nil error returned by "fn" aborts
+// the iteration and is returned to the caller, as well as any read error .
+func (this *AOF) ScanErr(sep []byte, fn func(data []byte, atEOF bool) error) error {
+	return this.scanFrom(0, sep, fn)
+}
+
+// Scan the datafile like Scan, but pass the id of each record along with its data,
+// so the records can be read again later using Get .
+func (this *AOF) ForEach(sep []byte, fn func(id string, data []byte) bool) {
+	offset := this.base
+	this.scanFrom(0, sep, func(data []byte, atEOF bool) error {
+		id := this.position(offset, int64(len(data))).String()
+		offset += int64(len(data) + len(sep))
+		if ! fn(id, data) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Scan the datafile like Scan, but starting at the byte "offset" .
+// This lets callers resume from the end of the last record they processed,
+// an offset beyond the current size is clamped to it so nothing is scanned .
+func (this *AOF) ScanFrom(offset int64, sep []byte, fn func(data []byte, atEOF bool) bool) {
+	this.scanFrom(offset, sep, func(data []byte, atEOF bool) error {
+		if ! fn(data, atEOF) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Scan the datafile like Scan, but read it in blocks of "bufSize" bytes instead of
+// Options.ScanBufferSize, bigger blocks mean fewer reads at the cost of memory, though
+// past a few KB the gain is small, see BenchmarkScanBufferSizes . It returns an error if "bufSize" is smaller than the separator .
+func (this *AOF) ScanBuffered(bufSize int, sep []byte, fn func(data []byte, atEOF bool) bool) error {
+	if bufSize <= 0 || bufSize < len(sep) {
+		return fmt.Errorf(`aof: scan buffer size %d is smaller than the separator`, bufSize)
+	}
+	err := this.scanBuffered(this.base, bufSize, sep, func(data []byte, atEOF bool) error {
+		if ! fn(data, atEOF) {
+			return errStop
 		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return err
+}
+
+// Return the size of the blocks read by the scans .
+func (this *AOF) scanBuffer() int {
+	if this.opts.ScanBufferSize > 0 {
+		return this.opts.ScanBufferSize
+	}
+	return scanBufferSize
+}
+
+// Scan the separated records starting at "offset" .
+func (this *AOF) scanFrom(offset int64, sep []byte, fn func(data []byte, atEOF bool) error) error {
+	return this.scanBuffered(offset, this.scanBuffer(), sep, fn)
+}
+
+// Scan the separated records starting at "offset" reading blocks of "bufSize" bytes .
+func (this *AOF) scanBuffered(offset int64, bufSize int, sep []byte, fn func(data []byte, atEOF bool) error) error {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return ErrClosed
 	}
-	data = []byte{}
+	if offset < this.base {
+		offset = this.base
+	}
+	if offset > this.size {
+		offset = this.size
+	}
+	return scanSeparated(io.NewSectionReader(this.file, offset, this.size - offset), sep, bufSize, fn)
 }
 
 // Scan the datafile in reverse order using a custom separator and function.
 // The provided function has two params, data and whether we at the end or not .
-// This function will lock the whole file till it ends .
+// The file is read backwards in blocks, the separator is detected at any offset .
+// This function will hold the read lock till it ends .
 func (this *AOF) ReverseScan(sep []byte, fn func(data []byte, atEOF bool) bool) {
+	this.reverseScan(sep, func(data []byte, atEOF bool) error {
+		if ! fn(data, atEOF) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Scan the separated records backwards from the end of the datafile .
+func (this *AOF) reverseScan(sep []byte, fn func(data []byte, atEOF bool) error) error {
+	return this.reverseScanFrom(math.MaxInt64, sep, fn)
+}
+
+// Scan the datafile in reverse order like ReverseScan, but starting backwards from the byte "offset",
+// so the first record passed to "fn" is the one ending right before it . This lets callers
+// page backward, a record and its preceding separator take len(data) + len(sep) bytes,
+// so the offset to resume from is "offset" minus those of the records consumed .
+// An offset beyond the current size is clamped to it .
+func (this *AOF) ReverseScanFrom(offset int64, sep []byte, fn func(data []byte, atEOF bool) bool) {
+	this.reverseScanFrom(offset, sep, func(data []byte, atEOF bool) error {
+		if ! fn(data, atEOF) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Scan the separated records backwards from "offset" .
+func (this *AOF) reverseScanFrom(offset int64, sep []byte, fn func(data []byte, atEOF bool) error) error {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return ErrClosed
+	}
+	if offset > this.size {
+		offset = this.size
+	}
+	if offset < this.base {
+		offset = this.base
+	}
+	return reverseScanSeparated(this.file, this.base, offset, sep, this.scanBuffer(), fn)
+}
+
+// Return up to the first "n" records separated by "sep" .
+func (this *AOF) First(n int, sep []byte) ([][]byte, error) {
+	records := [][]byte{}
+	if n <= 0 {
+		return records, nil
+	}
+	err := this.scanFrom(0, sep, func(data []byte, atEOF bool) error {
+		records = append(records, data)
+		if len(records) >= n {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Return up to the last "n" records separated by "sep" .
+// The records are in file order, the oldest of them first and the newest one last .
+func (this *AOF) Last(n int, sep []byte) ([][]byte, error) {
+	records := [][]byte{}
+	if n <= 0 {
+		return records, nil
+	}
+	err := this.reverseScan(sep, func(data []byte, atEOF bool) error {
+		records = append(records, data)
+		if len(records) >= n {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	for i, j := 0, len(records) - 1; i < j; i, j = i + 1, j - 1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// Clear the contents of the file and reset our size .
+// In segmented mode the sealed segments are deleted too .
+func (this *AOF) Clear() error {
 	this.Lock()
 	defer this.Unlock()
-	pos := int64(0)
-	done := int64(0)
-	data := []byte{}
-	for {
-		this.file.Seek(pos, 2)
-		tmp := make([]byte, len(sep))
-		n, _ := this.file.Read(tmp)
-		pos -= int64(len(sep))
-		if n > 0 {
-			done += int64(n)
-			data = append(tmp, data ...)
-		}
-		if bytes.Equal(sep, tmp) {
-			if ! fn(bytes.Trim(data, string(sep)), false) {
-				break
-			}
-			data = []byte{}
-		}
-		if done >= this.size {
-			fn(bytes.Trim(data, string(sep)), true)
+	if err := this.writable(); err != nil {
+		return err
+	}
+	if err := this.dropSegments(len(this.segments)); err != nil {
+		return err
+	}
+	if err := this.truncate(this.base); err != nil {
+		return err
+	}
+	if this.index != nil {
+		this.index, this.indexed = this.index[:0], 0
+	}
+	if this.deleted != nil {
+		this.deleted = map[Position]struct{}{}
+	}
+	if this.dedup != nil {
+		this.dedup = map[[sha256.Size]byte]Position{}
+	}
+	return nil
+}
+
+// Truncate the datafile back to the known good "offset", dropping everything after it .
+// The indexed records that don't fit before "offset" are dropped from the index too .
+func (this *AOF) TruncateAt(offset int64) error {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return err
+	}
+	if offset < this.base || offset > this.size {
+		return fmt.Errorf(`%w: truncate offset %d isn't in [%d, %d]`, ErrOutOfRange, offset, this.base, this.size)
+	}
+	if err := this.truncate(offset); err != nil {
+		return err
+	}
+	this.trimIndex(offset)
+	if this.deleted != nil {
+		this.loadTombstones()
+	}
+	return nil
+}
+
+// Drop the indexed records of the active segment that don't fit before "offset" anymore,
+// once the datafile was truncated there, the caller must hold the write lock .
+func (this *AOF) trimIndex(offset int64) {
+	for len(this.index) > 0 && this.indexStride() == 1 {
+		p := this.index[len(this.index)-1]
+		if p.Segment != this.segment || p.Offset + p.Length <= offset {
 			break
 		}
+		this.index, this.indexed = this.index[:len(this.index)-1], this.indexed - 1
+	}
+	if this.index != nil && this.indexStride() > 1 {
+		this.buildIndex()
 	}
-	data = []byte{}
 }
 
-// Clear the contents of the file
-func (this *AOF) Clear() error {
-	return this.file.Truncate(0)
+// Truncate the datafile at "offset" and update our size accordingly,
+// the caller must hold the write lock .
+func (this *AOF) truncate(offset int64) error {
+	this.cache.reset()
+	if this.rangeSynced > offset {
+		this.rangeSynced = offset
+	}
+	if err := this.file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := this.file.Seek(offset, 0); err != nil {
+		return err
+	}
+	this.size = offset
+	return nil
+}
+
+// Reserve "bytes" of disk space past the end of the datafile, so the following
+// appends don't grow it piece by piece and fail early if the disk is short of space .
+// Neither our size nor the append offset change, it uses fallocate on Linux and
+// F_PREALLOCATE on macOS, elsewhere it's a no-op .
+func (this *AOF) Preallocate(bytes int64) error {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return err
+	}
+	if bytes <= 0 {
+		return nil
+	}
+	file, ok := this.file.(*os.File)
+	if ! ok {
+		return nil
+	}
+	return preallocate(file, this.size, bytes)
+}
+
+// Stat the datafile and take its size as ours, in case it changed behind our back .
+// It returns the corrected size, Size stays the cheap cached variant .
+func (this *AOF) RefreshSize() (int64, error) {
+	this.Lock()
+	defer this.Unlock()
+	if this.closed {
+		return 0, ErrClosed
+	}
+	finfo, err := this.file.Stat()
+	if err != nil {
+		return this.size, err
+	}
+	this.size = finfo.Size()
+	this.grew()
+	return this.size, nil
+}
+
+// Check that the path of the datafile (of the active segment in segmented mode) still
+// leads to the file we have open, it returns ErrFileReplaced if it was deleted or
+// replaced by another process, an external log rotation for example, see Reopen .
+// On Unix the writes to a deleted file keep succeeding, on Windows an open file
+// can't be deleted nor renamed unless it's opened with FILE_SHARE_DELETE, so it's mostly a Unix concern .
+func (this *AOF) Healthy() error {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return ErrClosed
+	}
+	if this.path == `` {
+		return fmt.Errorf(`aof: can't check an AOF without a path`)
+	}
+	open, err := this.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(this.activePath())
+	if os.IsNotExist(err) {
+		return ErrFileReplaced
+	}
+	if err != nil {
+		return err
+	}
+	if ! os.SameFile(open, current) {
+		return ErrFileReplaced
+	}
+	return nil
 }
 
 // Return the size of our log file .
@@ -138,8 +1065,98 @@ func (this *AOF) Size() int64 {
 	return this.size
 }
 
+// Return the path of our log file .
+func (this *AOF) Path() string {
+	return this.path
+}
+
+// Return the base name of our log file .
+func (this *AOF) Name() string {
+	return filepath.Base(this.path)
+}
+
 // Close the AOF file .
-func (this *AOF) Close() {
-	this.file.Close()
-	this = nil
+// Pending writes are synced first unless the sync policy is SyncNever,
+// the file of an AOF opened by OpenTemp is deleted afterward .
+// Closing an already closed AOF returns ErrClosed .
+func (this *AOF) Close() error {
+	this.Lock()
+	if this.closed {
+		this.Unlock()
+		return ErrClosed
+	}
+	this.closed = true
+	done := this.done
+	this.Unlock()
+	close(done)
+	this.flusher.Wait()
+	if this.committed != nil {
+		<-this.committed
+	}
+	err := this.flushErr
+	if ! this.opts.Sync.never && this.opts.Sync.interval <= 0 && ! this.readOnly {
+		err = this.syncFile(this.file)
+	}
+	this.unmap()
+	if file, ok := this.file.(*os.File); ok && ! this.unlocked {
+		unlockFile(file)
+	}
+	if e := this.file.Close(); err == nil {
+		err = e
+	}
+	for _, seg := range this.segments {
+		seg.file.Close()
+	}
+	if this.temp {
+		if e := os.Remove(this.path); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Reopen the datafile of a closed AOF with its original mode and options,
+// after it was rotated by an external tool or a failed operation for example .
+// The state is read again from the file, the OnWrite hooks and the metrics are kept .
+// Reopening an AOF that isn't closed is a no-op .
+func (this *AOF) Reopen() error {
+	this.Lock()
+	defer this.Unlock()
+	if ! this.closed {
+		return nil
+	}
+	if this.path == `` {
+		return fmt.Errorf(`aof: can't reopen an AOF without a path`)
+	}
+	var fresh *AOF
+	var err error
+	if this.readOnly {
+		fresh, err = OpenReadOnly(this.path)
+	} else {
+		opts := this.opts
+		opts.Sync.interval, opts.PublishExpvar, opts.GroupCommit = 0, false, 0
+		fresh, err = OpenWithOptions(this.path, this.mode, opts)
+	}
+	if err != nil {
+		return err
+	}
+	this.file, this.size, this.segment, this.segments = fresh.file, fresh.size, fresh.segment, fresh.segments
+	this.index, this.indexed, this.deleted = fresh.index, fresh.indexed, fresh.deleted
+	this.base, this.flags = fresh.base, fresh.flags
+	this.mapping, this.aead, this.dedup = fresh.mapping, fresh.aead, fresh.dedup
+	this.closed, this.flushErr = false, nil
+	this.cache.reset()
+	this.done = make(chan struct{})
+	if this.opts.PublishExpvar {
+		publish(this)
+	}
+	if this.opts.Sync.interval > 0 {
+		this.synced = make(chan struct{}, 1)
+		this.flusher.Add(1)
+		go this.flush(this.opts.Sync.interval)
+	}
+	if this.opts.GroupCommit > 0 && ! this.readOnly {
+		this.startGroupCommit()
+	}
+	return nil
 }
@@ -0,0 +1,146 @@
+package aof
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClearThenPut(t *testing.T) {
+	a, err := Open(filepath.Join(t.TempDir(), `clear.aof`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if _, err := a.PutBytes([]byte(`before the clear`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Size() != 0 {
+		t.Fatalf(`the size is %d after Clear`, a.Size())
+	}
+	id, err := a.PutBytes([]byte(`after`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := hex.DecodeString(id); string(raw) != `0:5` {
+		t.Fatalf(`the id is %q, expected "0:5"`, raw)
+	}
+	if data, err := a.GetAll(id); err != nil || string(data) != `after` {
+		t.Fatalf(`got %q and %v, expected "after"`, data, err)
+	}
+	if a.Size() != 5 {
+		t.Fatalf(`the size is %d, expected 5`, a.Size())
+	}
+}
+
+// A datafile whose writes fail once "limit" bytes were written in total .
+type failingFile struct {
+	*memFile
+	limit	int
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if len(p) > f.limit {
+		n, _ := f.memFile.Write(p[0:f.limit])
+		f.limit = 0
+		return n, errors.New(`disk full`)
+	}
+	f.limit -= len(p)
+	return f.memFile.Write(p)
+}
+
+// A reader failing after its data .
+type failingReader struct {
+	data	[]byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New(`connection reset`)
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestPutPartialWrite(t *testing.T) {
+	a := OpenMemory()
+	defer a.Close()
+	file := &failingFile{memFile: a.file.(*memFile), limit: 10}
+	a.file = file
+	if _, err := a.PutBytes([]byte(`first`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Put(strings.NewReader(`a record failing after 5 bytes`)); err == nil {
+		t.Fatal(`the partial write should fail`)
+	}
+	if _, err := a.Put(&failingReader{[]byte(`half`)}); err == nil {
+		t.Fatal(`the failing reader should fail the write`)
+	}
+	if a.Size() != 5 || len(file.data) != 5 {
+		t.Fatalf(`the size is %d and the file holds %d bytes, expected 5`, a.Size(), len(file.data))
+	}
+	file.limit = 100
+	id, err := a.PutBytes([]byte(`second`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := ParsePosition(id); p.Offset != 5 {
+		t.Fatalf(`the record is at %d, expected 5`, p.Offset)
+	}
+	if data, err := a.GetAll(id); err != nil || string(data) != `second` {
+		t.Fatalf(`got %q and %v, expected "second"`, data, err)
+	}
+}
+
+func TestSyncIntervalFlusher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `interval.aof`)
+	a, err := OpenWithOptions(path, 0644, Options{Sync: SyncInterval(50 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := a.PutBytes([]byte(`synced by the flusher`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := a.Metrics().Fsyncs; n != 0 {
+		t.Fatalf(`the write synced %d times, the flusher should sync it`, n)
+	}
+	for deadline := time.Now().Add(5 * time.Second); a.Metrics().Fsyncs == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal(`the flusher didn't sync within 5s`)
+		}
+	}
+	r, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := r.GetAll(id); err != nil || string(data) != `synced by the flusher` {
+		t.Fatalf(`got %q and %v`, data, err)
+	}
+	r.Close()
+	last, err := a.PutBytes([]byte(`synced by Close`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := a.Metrics().Fsyncs
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Metrics().Fsyncs <= before {
+		t.Fatal(`Close didn't sync the pending writes`)
+	}
+	if r, err = OpenReadOnly(path); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if data, err := r.GetAll(last); err != nil || string(data) != `synced by Close` {
+		t.Fatalf(`got %q and %v`, data, err)
+	}
+}
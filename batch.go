@@ -0,0 +1,98 @@
+package aof
+
+import (
+	"fmt"
+	"time"
+)
+
+// Write all the items under a single lock and a single sync, framed as
+// configured by Options.Framing (always framed with compression, encryption or timestamps),
+// and return their ids in order .
+// The ids always point to the payloads, so they can be read using Get .
+// The batch is all or nothing, on failure no item is left in the datafile .
+func (this *AOF) PutBatch(items [][]byte) ([]string, error) {
+	positions, err := this.AppendAll(items)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(positions))
+	for i, p := range positions {
+		ids[i] = p.String()
+	}
+	return ids, nil
+}
+
+// Write all the items like PutBatch, joined into a single buffer written at once
+// and synced once, but return their Positions, sparing the formatting of the ids .
+// It's the fast path to ingest many tiny records, much cheaper than a Put per record .
+func (this *AOF) AppendAll(items [][]byte) ([]Position, error) {
+	positions, err := this.putBatch(items)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range positions {
+		this.notify(p)
+	}
+	return positions, nil
+}
+
+// Encode the items as configured and write them at once under the write lock .
+func (this *AOF) putBatch(items [][]byte) ([]Position, error) {
+	var flags byte
+	switch this.opts.Framing {
+	case FramingNone:
+	case FramingLength:
+	case FramingChecked:
+		flags = frameChecked
+	default:
+		return nil, fmt.Errorf(`aof: unknown framing %d`, this.opts.Framing)
+	}
+	framed := this.opts.Framing != FramingNone || this.transforms()
+	now := time.Now().UnixNano()
+	bufs := make([][]byte, len(items))
+	headers := make([]int64, len(items))
+	lengths := make([]int64, len(items))
+	for i, item := range items {
+		bufs[i], lengths[i] = item, int64(len(item))
+		if ! framed {
+			continue
+		}
+		stored, flags, err := this.encodeRecord(item, flags)
+		if err != nil {
+			return nil, err
+		}
+		frame, err := encodeFrame(stored, frame{flags: flags, ts: now})
+		if err != nil {
+			return nil, err
+		}
+		lengths[i] = int64(len(stored))
+		headers[i] = int64(len(frame) - len(stored))
+		bufs[i] = frame
+	}
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return nil, err
+	}
+	var offsets []int64
+	var err error
+	if framed {
+		offsets, err = this.writeFrames(bufs ...)
+	} else {
+		// the unframed items aren't padded
+		offsets, err = this.writeAligned(bufs, make([]bool, len(bufs)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]Position, len(items))
+	for i := range items {
+		positions[i] = this.position(offsets[i] + headers[i], lengths[i])
+	}
+	if this.index != nil && framed {
+		for _, p := range positions {
+			this.indexRecord(p)
+		}
+	}
+	return positions, nil
+}
@@ -0,0 +1,41 @@
+package aof
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// 10k tiny records, written by one AppendAll or one PutBytes each
+func benchmarkTinyRecords(b *testing.B, batch bool) {
+	a, err := Open(filepath.Join(b.TempDir(), `tiny.aof`), 0644)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer a.Close()
+	items := make([][]byte, 10000)
+	for i := range items {
+		items[i] = []byte(`tiny`)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batch {
+			if _, err := a.AppendAll(items); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		for _, item := range items {
+			if _, err := a.PutBytes(item); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkAppendAllTinyRecords(b *testing.B) {
+	benchmarkTinyRecords(b, true)
+}
+
+func BenchmarkPutBytesTinyRecords(b *testing.B) {
+	benchmarkTinyRecords(b, false)
+}
@@ -0,0 +1,91 @@
+package aof
+
+import (
+	"container/list"
+	"sync"
+)
+
+// An LRU cache of the decoded records bounded by the number of cached bytes,
+// a nil cache is a disabled one . The records are never changed by appends, so it only
+// has to be reset when the datafile is truncated, rewritten or loses segments .
+type readCache struct {
+	sync.Mutex
+	max	int
+	size	int
+	items	map[cacheKey]*list.Element
+	order	*list.List
+}
+
+// A cached record, by its Position or by the offset of its frame .
+type cacheKey struct {
+	pos	Position
+	frame	bool
+}
+
+// A cached record and the flags of its frame .
+type cacheEntry struct {
+	key	cacheKey
+	flags	byte
+	data	[]byte
+}
+
+// Create a cache of "max" bytes, it returns nil if "max" isn't positive .
+func newReadCache(max int) *readCache {
+	if max <= 0 {
+		return nil
+	}
+	return &readCache{max: max, items: map[cacheKey]*list.Element{}, order: list.New()}
+}
+
+// Whether a record of "length" bytes may be cached .
+func (this *readCache) fits(length int64) bool {
+	return this != nil && length <= int64(this.max)
+}
+
+// Return the cached record of "key" if any, making it the most recently used .
+func (this *readCache) get(key cacheKey) (cacheEntry, bool) {
+	if this == nil {
+		return cacheEntry{}, false
+	}
+	this.Lock()
+	defer this.Unlock()
+	elem, ok := this.items[key]
+	if ! ok {
+		return cacheEntry{}, false
+	}
+	this.order.MoveToFront(elem)
+	return *elem.Value.(*cacheEntry), true
+}
+
+// Cache the record of "key" evicting the least recently used ones till it fits .
+func (this *readCache) put(key cacheKey, flags byte, data []byte) {
+	if this == nil || len(data) > this.max {
+		return
+	}
+	this.Lock()
+	defer this.Unlock()
+	if _, ok := this.items[key]; ok {
+		return
+	}
+	for this.size + len(data) > this.max {
+		oldest := this.order.Back()
+		this.order.Remove(oldest)
+		entry := oldest.Value.(*cacheEntry)
+		delete(this.items, entry.key)
+		this.size -= len(entry.data)
+	}
+	this.items[key] = this.order.PushFront(&cacheEntry{key, flags, data})
+	this.size += len(data)
+}
+
+// Drop all the cached records .
+func (this *readCache) reset() {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	defer this.Unlock()
+	this.items = map[cacheKey]*list.Element{}
+	this.order.Init()
+	this.size = 0
+}
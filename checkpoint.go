@@ -0,0 +1,109 @@
+package aof
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Return the path of the checkpoint file of the consumer "name" .
+func (this *AOF) checkpointPath(name string) (string, error) {
+	if name == `` || strings.ContainsAny(name, `/\`) || name == `.` || name == `..` {
+		return ``, fmt.Errorf(`aof: invalid checkpoint name %q`, name)
+	}
+	if this.path == `` {
+		return ``, fmt.Errorf(`aof: can't checkpoint an AOF without a path`)
+	}
+	return this.path + `.` + name + `.checkpoint`, nil
+}
+
+// Persist the progress "offset" of the consumer "name" into a file next to the datafile,
+// so each consumer of the log resumes ScanFrom or Follow from where it stopped .
+// The file is written to a temp file which is atomically renamed over the previous one .
+func (this *AOF) SaveCheckpoint(name string, offset int64) error {
+	path, err := this.checkpointPath(name)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + `-*`)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(strconv.FormatInt(offset, 10) + "\n")
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Read the offset saved by the consumer "name" using SaveCheckpoint,
+// it's 0 if the consumer didn't save any yet .
+func (this *AOF) LoadCheckpoint(name string) (int64, error) {
+	path, err := this.checkpointPath(name)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf(`aof: corrupt checkpoint %q: %v`, name, err)
+	}
+	return offset, nil
+}
+
+// The name of the checkpoint holding the head of Drain .
+const drainCheckpoint = `drain`
+
+// Consume at most "n" framed records from the head of the log, the oldest ones not drained
+// yet, and advance the head past them, so they're never returned again, even after a restart
+// since the head is persisted as the "drain" checkpoint . This turns the log into a durable
+// FIFO queue, which is opt-in: the records stay in the datafile and the other readers still see
+// them, and nothing but Drain moves the head . The head is only advanced once the records are
+// read, so a failure returns them again the next time, but a crash between the return and the
+// processing of the records loses them . Rewriting the datafile (Clear, Compact, ...) moves the
+// records, so the head must be reset with SaveCheckpoint then, it's an offset of the active segment .
+func (this *AOF) Drain(n int) (records [][]byte, err error) {
+	if n <= 0 {
+		return nil, fmt.Errorf(`aof: drain count %d isn't positive`, n)
+	}
+	this.drainLock.Lock()
+	defer this.drainLock.Unlock()
+	head, err := this.LoadCheckpoint(drainCheckpoint)
+	if err != nil {
+		return nil, err
+	}
+	records = [][]byte{}
+	next, err := this.WalkFrom(head, n, func(pos Position, data []byte) error {
+		records = append(records, data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if next == head {
+		return records, nil
+	}
+	if err := this.SaveCheckpoint(drainCheckpoint, next); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,320 @@
+package aof
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Return the path of the file we append to .
+func (this *AOF) activePath() string {
+	if this.segment > 0 {
+		return segmentPath(this.path, this.segment)
+	}
+	return this.path
+}
+
+// Create a temp file next to the active file to rewrite it .
+func (this *AOF) createTemp(suffix string) (*os.File, error) {
+	path := this.activePath()
+	if path == `` {
+		return nil, fmt.Errorf(`aof: can't rewrite an AOF without a path`)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + `.` + suffix + `-*`)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Chmod(this.mode); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// Sync and atomically rename "tmp" over the active file, then switch to it,
+// the caller must hold the write lock .
+func (this *AOF) replaceWith(tmp *os.File) error {
+	defer os.Remove(tmp.Name())
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	path := this.activePath()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, this.mode)
+	if err != nil {
+		return err
+	}
+	finfo, err := file.Stat()
+	if err == nil {
+		err = lockFile(file, true)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	unlockFile(this.file.(*os.File))
+	this.unmap()
+	this.cache.reset()
+	this.file.Close()
+	this.file, this.size = file, finfo.Size()
+	this.rangeSynced = this.size
+	this.remap()
+	return nil
+}
+
+// Fsync a directory so a rename in it is durable, it's best effort
+// since not every platform supports it .
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Rewrite the framed datafile keeping only the records "keep" returns true for .
+// The records are copied to a temp file which is atomically renamed over the datafile,
+// so a crash leaves either the old or the new file, kept records get new offsets .
+// It returns "remap", mapping the old id of each kept record to its new id, so the ids
+// stored elsewhere can be updated, and the number of reclaimed bytes, a damaged datafile
+// isn't compacted, see Repair . The remap holds two ids per kept record in memory, which is
+// a lot for a large datafile, CompactFunc streams them instead .
+// With Options.Tombstones the deleted records of the datafile are dropped too, along with their tombstones .
+func (this *AOF) Compact(keep func(data []byte) bool) (remap map[string]string, reclaimed int64, err error) {
+	remap = map[string]string{}
+	reclaimed, err = this.compact(keep, func(from, to Position) {
+		remap[from.String()] = to.String()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return remap, reclaimed, nil
+}
+
+// Compact the datafile like Compact, but pass the old and the new id of each kept record
+// to "moved" as it's copied, instead of collecting them into a map . It's called under the
+// write lock, so it must not use the AOF, and before the rewrite is committed, so the ids
+// it received are only valid if CompactFunc returns no error, which suits a transaction
+// of the external store committed once CompactFunc succeeds .
+func (this *AOF) CompactFunc(keep func(data []byte) bool, moved func(oldID, newID string)) (int64, error) {
+	return this.compact(keep, func(from, to Position) {
+		moved(from.String(), to.String())
+	})
+}
+
+// Rewrite the datafile keeping the records "keep" returns true for, passing the old and
+// the new Position of each one to "moved" .
+func (this *AOF) compact(keep func(data []byte) bool, moved func(from, to Position)) (reclaimed int64, err error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return 0, err
+	}
+	if this.opts.Logger != nil {
+		start := time.Now()
+		this.log(`compact_start`, map[string]any{`size`: this.size})
+		defer func() {
+			this.log(`compact_finish`, map[string]any{`reclaimed`: reclaimed, `duration`: time.Since(start), `error`: err})
+		}()
+	}
+	tmp, err := this.createTemp(`compact`)
+	if err != nil {
+		return 0, err
+	}
+	if this.base > 0 {
+		if err := this.writeHeader(tmp); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return 0, err
+		}
+	}
+	written := this.base
+	frames := this.frames(0)
+	for {
+		f, data, err := frames.next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && f.flags & framePadding != 0 {
+			continue
+		}
+		if err == nil && this.deleted != nil && this.dropped(f, data) {
+			continue
+		}
+		var decoded []byte
+		if err == nil && f.flags & frameTombstone == 0 {
+			decoded, err = this.decodeRecord(f.flags, data)
+			if err == nil && ! keep(decoded) {
+				continue
+			}
+		}
+		var buf []byte
+		if err == nil {
+			buf, err = encodeFrame(data, f)
+		}
+		if err == nil {
+			// the kept frames are realigned to their new offsets
+			buf = append(this.padding(written), buf ...)
+			_, err = tmp.Write(buf)
+			written += int64(len(buf))
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return 0, err
+		}
+		if f.flags & frameTombstone == 0 {
+			moved(this.position(f.payload(), f.length), this.position(written - f.length, f.length))
+		}
+	}
+	before := this.size
+	if err := this.replaceWith(tmp); err != nil {
+		return 0, err
+	}
+	if this.index != nil {
+		this.buildIndex()
+	}
+	for p := range this.deleted {
+		if p.Segment == this.segment {
+			delete(this.deleted, p)
+		}
+	}
+	return before - this.size, nil
+}
+
+// Whether Compact drops the frame "f" carrying "data", which is the case of the deleted
+// records of the active segment and of their tombstones, the caller must hold the lock .
+func (this *AOF) dropped(f frame, data []byte) bool {
+	if f.flags & frameTombstone == 0 {
+		return this.isDeleted(f)
+	}
+	p, err := ParsePosition(string(data))
+	return err == nil && p.Segment == this.segment
+}
+
+// Rewrite the framed datafile keeping only its intact frames, dropping the zero filled
+// holes and the unreadable regions between them, which a sparse file or a manual
+// truncation followed by appends leaves, it's a recovery tool for damaged datafiles .
+// After a bad region, only a checked frame is accepted as the next intact one, since only
+// its checksum tells a frame apart from the garbage or the zeros that happen to decode as one,
+// so the unchecked frames following a bad region are dropped, like the empty unframed records
+// which can't be told apart from holes . It returns the number of removed bytes,
+// and refuses to run if no intact frame is found in a non empty datafile .
+func (this *AOF) Vacuum() (int64, error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return 0, err
+	}
+	tmp, err := this.createTemp(`vacuum`)
+	if err != nil {
+		return 0, err
+	}
+	fail := func(err error) (int64, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if this.base > 0 {
+		if err := this.writeHeader(tmp); err != nil {
+			return fail(err)
+		}
+	}
+	found, resync := false, false
+	written := this.base
+	for offset := this.base; offset < this.size; {
+		if resync {
+			next, err := this.nextChecked(offset)
+			if err != nil {
+				return fail(err)
+			}
+			if offset = next; offset >= this.size {
+				break
+			}
+		}
+		f, data, err := this.intactFrame(offset)
+		if err == nil && resync && f.flags & frameChecked == 0 {
+			err = ErrBadFrame
+		}
+		if err != nil {
+			offset, resync = offset + 1, true
+			continue
+		}
+		resync = false
+		if offset = f.end(); f.flags & framePadding != 0 {
+			continue
+		}
+		found = true
+		buf, err := encodeFrame(data, f)
+		if err == nil {
+			buf = append(this.padding(written), buf ...)
+			_, err = tmp.Write(buf)
+			written += int64(len(buf))
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if ! found && this.size > this.base {
+		return fail(fmt.Errorf(`aof: no intact frame found to vacuum from`))
+	}
+	before := this.size
+	if err := this.replaceWith(tmp); err != nil {
+		return 0, err
+	}
+	if this.index != nil {
+		this.buildIndex()
+	}
+	if this.deleted != nil {
+		this.loadTombstones()
+	}
+	return before - this.size, nil
+}
+
+// Return the first offset from "offset" on where a checked frame may start, which is where
+// the flags byte has the frameChecked bit, or the size if there is none, so the zero filled
+// holes and most of the garbage are skipped a buffered block at a time, the caller must hold the lock .
+func (this *AOF) nextChecked(offset int64) (int64, error) {
+	block := make([]byte, scanBufferSize)
+	for pos := offset + frameFlagsOffset; pos < this.size; pos += int64(len(block)) {
+		n := int64(len(block))
+		if this.size - pos < n {
+			n = this.size - pos
+		}
+		if _, err := this.file.ReadAt(block[0:n], pos); err != nil {
+			return 0, err
+		}
+		for i, b := range block[0:n] {
+			if b & frameChecked != 0 {
+				return pos + int64(i) - frameFlagsOffset, nil
+			}
+		}
+	}
+	return this.size, nil
+}
+
+// Read the frame at "offset" if it's intact and isn't a zero filled hole,
+// the caller must hold the lock .
+func (this *AOF) intactFrame(offset int64) (frame, []byte, error) {
+	f, err := decodeFrame(io.NewSectionReader(this.file, offset, this.size - offset), offset, this.size)
+	if err != nil {
+		return f, nil, err
+	}
+	if f.flags == 0 && f.length == 0 {
+		return f, nil, ErrBadFrame
+	}
+	data := make([]byte, f.length)
+	if _, err := this.file.ReadAt(data, f.payload()); err != nil {
+		return f, nil, err
+	}
+	return f, data, f.verify(data)
+}
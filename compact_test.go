@@ -0,0 +1,88 @@
+package aof
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVacuumHoleBeforeCheckedFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `vacuum.aof`)
+	a, err := Open(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	records := [][]byte{[]byte(`first`)}
+	if _, err := a.PutChecked(records[0]); err != nil {
+		t.Fatal(err)
+	}
+	// a hole, like the one left by a truncation followed by appends
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := a.RefreshSize(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		record := bytes.Repeat([]byte{'a' + byte(i)}, 60)
+		records = append(records, record)
+		if _, err := a.PutChecked(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := a.Vacuum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1000 {
+		t.Fatalf(`removed %d bytes, expected 1000`, removed)
+	}
+	var got [][]byte
+	a.ScanFramed(func(offset int64, data []byte) bool {
+		got = append(got, data)
+		return true
+	})
+	if len(got) != len(records) {
+		t.Fatalf(`got %d records, expected %d`, len(got), len(records))
+	}
+	for i := range records {
+		if ! bytes.Equal(got[i], records[i]) {
+			t.Fatalf(`record %d is %q, expected %q`, i, got[i], records[i])
+		}
+	}
+}
+
+func TestVacuumLargeHole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `vacuum.aof`)
+	a, err := Open(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if _, err := a.PutChecked([]byte(`before`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, a.Size() + 8 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.RefreshSize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.PutChecked([]byte(`after`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Vacuum(); err != nil {
+		t.Fatal(err)
+	}
+	n, _, err := a.Validate()
+	if err != nil || n != 2 {
+		t.Fatalf(`got %d records and %v, expected 2 records`, n, err)
+	}
+}
@@ -0,0 +1,48 @@
+package aof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Compression selects how records are compressed before being written .
+type Compression int
+
+const (
+	// Store the records as is .
+	NoCompression	Compression	= iota
+
+	// Gzip the records into compressed frames .
+	GzipCompression
+)
+
+// Gzip "data" at the configured level .
+func (this *AOF) compress(data []byte) ([]byte, error) {
+	level := this.opts.CompressionLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Gunzip "data" .
+func decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
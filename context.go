@@ -0,0 +1,92 @@
+package aof
+
+import (
+	"context"
+	"io"
+)
+
+// The size of the chunks PutContext copies between context checks .
+const putChunkSize = 32 << 10
+
+// Write from an io.Reader like Put, checking "ctx" between chunks of the copy .
+// Once "ctx" is done the copy is aborted, the partially written record is
+// truncated away and the context error is returned . With Options.Compression, EncryptionKey,
+// Timestamps or Dedup the record is read in memory first, checking "ctx" the same, then
+// stored like Put does .
+func (this *AOF) PutContext(ctx context.Context, src io.Reader) (string, error) {
+	if this.transforms() || this.opts.Dedup {
+		data, err := readContext(ctx, src)
+		if err != nil {
+			return ``, err
+		}
+		return this.PutBytes(data)
+	}
+	p, err := this.putContext(ctx, src)
+	if err != nil {
+		return ``, err
+	}
+	this.notify(p)
+	return p.String(), nil
+}
+
+// Copy "src" chunk by chunk to the datafile under the write lock .
+func (this *AOF) putContext(ctx context.Context, src io.Reader) (Position, error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return Position{}, err
+	}
+	if err := this.rotate(1); err != nil {
+		return Position{}, err
+	}
+	offset := this.size
+	rollback := func(err error) (Position, error) {
+		this.truncate(offset)
+		return Position{}, err
+	}
+	buf := make([]byte, putChunkSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return rollback(err)
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			written, e := this.file.Write(buf[0:n])
+			this.metrics.written.Add(int64(written))
+			this.size += int64(written)
+			if e != nil {
+				return rollback(e)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rollback(err)
+		}
+	}
+	if err := this.commit(this.size); err != nil {
+		return rollback(err)
+	}
+	this.grew()
+	return this.position(offset, this.size - offset), nil
+}
+
+// Read "src" till its end, checking "ctx" between chunks .
+func readContext(ctx context.Context, src io.Reader) ([]byte, error) {
+	var data []byte
+	buf := make([]byte, putChunkSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := src.Read(buf)
+		data = append(data, buf[0:n] ...)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package aof
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutContextTransforms(t *testing.T) {
+	a, err := OpenWithOptions(filepath.Join(t.TempDir(), `context.aof`), 0644, Options{Compression: GzipCompression, Timestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	data := bytes.Repeat([]byte(`compressible `), 100)
+	id, err := a.PutContext(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.GetAll(id); err != nil || ! bytes.Equal(got, data) {
+		t.Fatalf(`got %d bytes and %v, expected %d bytes`, len(got), err, len(data))
+	}
+	if a.Size() >= int64(len(data)) {
+		t.Fatalf(`the record of %d bytes takes %d bytes, it isn't compressed`, len(data), a.Size())
+	}
+	timed := 0
+	a.ForEachTimed(func(pos Position, ts time.Time, data []byte) bool {
+		if ! ts.IsZero() {
+			timed++
+		}
+		return true
+	})
+	if timed != 1 {
+		t.Fatalf(`got %d timestamped records, expected 1`, timed)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := a.PutContext(ctx, bytes.NewReader(data)); err != context.Canceled {
+		t.Fatalf(`got %v, expected context.Canceled`, err)
+	}
+}
@@ -0,0 +1,78 @@
+package aof
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Hash the payloads of the framed records of all the segments, the caller must hold the write lock .
+func (this *AOF) loadDedup() {
+	this.dedup = map[[sha256.Size]byte]Position{}
+	files := []datafile{}
+	nums := []int{}
+	sizes := []int64{}
+	for _, seg := range this.segments {
+		files, nums, sizes = append(files, seg.file), append(nums, seg.num), append(sizes, seg.size)
+	}
+	files, nums, sizes = append(files, this.file), append(nums, this.segment), append(sizes, this.size)
+	for i, file := range files {
+		base, _, err := readHeader(file, sizes[i])
+		if err != nil {
+			continue
+		}
+		frames := newFrameScanner(file, base, sizes[i])
+		for {
+			f, stored, err := frames.nextRecord()
+			if err != nil {
+				break
+			}
+			data, err := this.decodeRecord(f.flags, stored)
+			if err != nil {
+				continue
+			}
+			p := Position{Offset: f.payload(), Length: f.length, Segment: nums[i]}
+			if _, deleted := this.deleted[p]; ! deleted {
+				this.dedup[sha256.Sum256(data)] = p
+			}
+		}
+	}
+}
+
+// Write "data" as a checked frame unless a live record already holds the same bytes,
+// whose Position is returned instead .
+func (this *AOF) putDedup(data []byte) (Position, error) {
+	sum := sha256.Sum256(data)
+	this.dedupLock.Lock()
+	defer this.dedupLock.Unlock()
+	if p, ok := this.duplicate(sum, data); ok {
+		return p, nil
+	}
+	_, p, err := this.putFrame(data, frameChecked)
+	if err != nil {
+		return Position{}, err
+	}
+	this.Lock()
+	this.dedup[sum] = p
+	this.Unlock()
+	return p, nil
+}
+
+// Return the Position of the live record holding "data" whose hash is "sum" .
+// The record is read back, so a stale entry left by a rewrite or a deletion is just a miss .
+func (this *AOF) duplicate(sum [sha256.Size]byte, data []byte) (Position, bool) {
+	this.RLock()
+	defer this.RUnlock()
+	p, ok := this.dedup[sum]
+	if _, deleted := this.deleted[p]; ! ok || deleted || this.closed {
+		return Position{}, false
+	}
+	file, size, err := this.segmentFile(p.Segment)
+	if err != nil || p.Offset + p.Length > size {
+		return Position{}, false
+	}
+	stored, err := this.readRecord(file, p)
+	if err != nil || ! bytes.Equal(stored, data) {
+		return Position{}, false
+	}
+	return p, true
+}
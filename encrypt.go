@@ -0,0 +1,43 @@
+package aof
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// Returned when an encrypted record can't be authenticated, because it was
+// tampered with or the key is wrong or missing .
+var ErrAuthFailed = errors.New(`aof: record authentication failed`)
+
+// Create the AES-GCM cipher of the records from "key" .
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal "data" using a random nonce, which is prepended to the ciphertext .
+func (this *AOF) encrypt(data []byte) ([]byte, error) {
+	nonce := make([]byte, this.aead.NonceSize(), this.aead.NonceSize() + len(data) + this.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return this.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// Open a payload sealed by encrypt .
+func (this *AOF) decrypt(data []byte) ([]byte, error) {
+	if this.aead == nil || len(data) < this.aead.NonceSize() {
+		return nil, ErrAuthFailed
+	}
+	nonce, ciphertext := data[0:this.aead.NonceSize()], data[this.aead.NonceSize():]
+	plain, err := this.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	return plain, nil
+}
@@ -0,0 +1,52 @@
+package aof
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptionLeavesNoPlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `encrypted.aof`)
+	a, err := OpenWithOptions(path, 0644, Options{EncryptionKey: bytes.Repeat([]byte{7}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	ids := map[string]string{}
+	if ids[`SECRET-PUTBYTES`], err = a.PutBytes([]byte(`SECRET-PUTBYTES`)); err != nil {
+		t.Fatal(err)
+	}
+	if ids[`SECRET-PUTCONTEXT`], err = a.PutContext(context.Background(), strings.NewReader(`SECRET-PUTCONTEXT`)); err != nil {
+		t.Fatal(err)
+	}
+	if ids[`"SECRET-PUTJSON"`], err = a.PutJSON(`SECRET-PUTJSON`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.PutLine(`SECRET-PUTLINE`); err == nil {
+		t.Fatal(`PutLine should refuse to write a plaintext line`)
+	}
+	if _, err := a.PutEscaped([]byte(`SECRET-PUTESCAPED`), []byte("\n")); err == nil {
+		t.Fatal(`PutEscaped should refuse to write a plaintext record`)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte(`SECRET`)) {
+		t.Fatalf(`the datafile holds plaintext: %q`, raw)
+	}
+	for want, id := range ids {
+		got, err := a.GetAll(id)
+		if err != nil || string(got) != want {
+			t.Fatalf(`got %q and %v, expected %q`, got, err, want)
+		}
+	}
+	var s string
+	if err := a.GetJSON(ids[`"SECRET-PUTJSON"`], &s); err != nil || s != `SECRET-PUTJSON` {
+		t.Fatalf(`got %q and %v`, s, err)
+	}
+}
@@ -0,0 +1,115 @@
+package aof
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The byte stuffing of the escaped records: the escape byte and the first byte of the
+// separator are stored as the escape byte followed by their code, so an escaped record
+// never contains the first byte of the separator, thus never the separator itself .
+const (
+	escapeByte	= 0x1b
+	escapeCode	= 0x01
+	escapeSepCode	= 0x02
+)
+
+// Returned by ScanEscaped when a record holds an escape byte that isn't followed by a code .
+var ErrBadEscape = errors.New(`aof: bad escape sequence`)
+
+// Return an error if "sep" can't delimit escaped records .
+func checkEscapeSep(sep []byte) error {
+	if len(sep) == 0 {
+		return fmt.Errorf(`aof: the escaped records require a separator`)
+	}
+	switch sep[0] {
+	case escapeByte, escapeCode, escapeSepCode:
+		return fmt.Errorf(`aof: the separator can't start with the byte %#x of the escaping`, sep[0])
+	}
+	return nil
+}
+
+// Escape "data" for the separator "sep" into "dst" .
+func escape(dst, data, sep []byte) []byte {
+	for _, b := range data {
+		switch b {
+		case escapeByte:
+			dst = append(dst, escapeByte, escapeCode)
+		case sep[0]:
+			dst = append(dst, escapeByte, escapeSepCode)
+		default:
+			dst = append(dst, b)
+		}
+	}
+	return dst
+}
+
+// Undo the escaping of "data" for the separator "sep" .
+func unescape(data, sep []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != escapeByte {
+			out = append(out, data[i])
+			continue
+		}
+		if i++; i == len(data) {
+			return nil, ErrBadEscape
+		}
+		switch data[i] {
+		case escapeCode:
+			out = append(out, escapeByte)
+		case escapeSepCode:
+			out = append(out, sep[0])
+		default:
+			return nil, ErrBadEscape
+		}
+	}
+	return out, nil
+}
+
+// Append "data" escaped so it can't contain "sep", followed by "sep", which makes the
+// separator based scans safe for records holding any bytes, see ScanEscaped to read them back .
+// The escape byte 0x1b and the first byte of "sep" are stored as two bytes, so the separator
+// can't start with 0x1b, 0x01 nor 0x02 . The returned id covers the escaped data without
+// the separator, which Get returns as stored . The escaped records are stored as is,
+// so it fails with Options.Compression, EncryptionKey or Timestamps .
+func (this *AOF) PutEscaped(data, sep []byte) (string, error) {
+	if err := this.plain(`PutEscaped`); err != nil {
+		return ``, err
+	}
+	if err := checkEscapeSep(sep); err != nil {
+		return ``, err
+	}
+	buf := append(escape(make([]byte, 0, len(data) + len(sep)), data, sep), sep ...)
+	p, err := this.putBytes(buf)
+	if err != nil {
+		return ``, err
+	}
+	p.Length -= int64(len(sep))
+	this.notify(p)
+	return p.String(), nil
+}
+
+// Scan the records written by PutEscaped with the separator "sep", which are passed unescaped .
+// Iteration stops once "fn" returns false, a record that isn't escaped properly stops it
+// with ErrBadEscape .
+// This function will hold the read lock till it ends .
+func (this *AOF) ScanEscaped(sep []byte, fn func(data []byte) bool) error {
+	if err := checkEscapeSep(sep); err != nil {
+		return err
+	}
+	err := this.scanFrom(0, sep, func(data []byte, atEOF bool) error {
+		data, err := unescape(data, sep)
+		if err != nil {
+			return err
+		}
+		if ! fn(data) {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return err
+}
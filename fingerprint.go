@@ -0,0 +1,43 @@
+package aof
+
+import (
+	"hash/fnv"
+	"io"
+)
+
+// Return the 64 bit FNV-1a hash of the records of the datafile, streamed from the file
+// and bounded by the size at the time of the call, so two logs holding the same bytes,
+// the header aside, have the same fingerprint, a cheap check to run before a full diff .
+// This function will hold the read lock till it ends .
+func (this *AOF) Fingerprint() (uint64, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return 0, ErrClosed
+	}
+	h := fnv.New64a()
+	if _, err := io.Copy(h, io.NewSectionReader(this.file, this.base, this.size - this.base)); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
+
+// Return the 64 bit FNV-1a hash of the decoded payload of every framed record, in order,
+// so the first diverging record of two logs is found without comparing the payloads,
+// even if they're compressed or encrypted differently . The tombstones are skipped,
+// and a truncated or bad frame stops it with the error that was hit .
+// This function will hold the read lock till it ends .
+func (this *AOF) RecordFingerprints() ([]uint64, error) {
+	sums := []uint64{}
+	h := fnv.New64a()
+	err := this.eachFrame(0, func(f frame, data []byte) error {
+		h.Reset()
+		h.Write(data)
+		sums = append(sums, h.Sum64())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
@@ -0,0 +1,56 @@
+package aof
+
+// Write "data" as a checked frame tagged with the user "flags", a control record for example,
+// without embedding them in the payload . It returns the Position of the payload,
+// which GetWithFlags expects .
+func (this *AOF) PutWithFlags(data []byte, flags byte) (Position, error) {
+	_, p, err := this.putFrameWith(data, frameChecked | frameUserFlags, flags)
+	return p, err
+}
+
+// Read the record at "p" along with the user flags it was written with by PutWithFlags,
+// the flags are 0 for the records written otherwise .
+func (this *AOF) GetWithFlags(p Position) ([]byte, byte, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return nil, 0, ErrClosed
+	}
+	file, _, err := this.segmentFile(p.Segment)
+	if err != nil {
+		return nil, 0, err
+	}
+	read := file.ReadAt
+	if file == this.file {
+		read = this.readAt
+	}
+	f, ok, err := lookback(read, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ! ok || f.flags & frameUserFlags == 0 {
+		data, err := this.readRecord(file, p)
+		return data, 0, err
+	}
+	stored := make([]byte, p.Length)
+	if _, err := read(stored, p.Offset); err != nil {
+		return nil, 0, err
+	}
+	if err := f.verify(stored); err != nil {
+		return nil, 0, err
+	}
+	this.metrics.reads.Add(1)
+	data, err := this.decodeRecord(f.flags, stored)
+	return data, f.user, err
+}
+
+// Walk the framed records like ForEachFramed, but pass the user flags of each one too .
+// This function will hold the read lock till it ends .
+func (this *AOF) ForEachWithFlags(fn func(pos Position, flags byte, data []byte) bool) {
+	this.eachFrame(0, func(f frame, data []byte) error {
+		if ! fn(this.position(f.payload(), f.length), f.user, data) {
+			return errStop
+		}
+		return nil
+	})
+}
@@ -0,0 +1,17 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package aof
+
+import (
+	"os"
+)
+
+// Advisory locks aren't supported on this platform, so this is a no-op .
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// Advisory locks aren't supported on this platform, so this is a no-op .
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,44 @@
+package aof
+
+import (
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestOpenReadOnlyWithWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `locked.aof`)
+	w, err := Open(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	id, err := w.PutBytes([]byte(`record`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch runtime.GOOS {
+	case `darwin`, `dragonfly`, `freebsd`, `linux`, `netbsd`, `openbsd`, `windows`:
+		if _, err := Open(path, 0644); err != ErrLocked {
+			t.Fatalf(`a second writer got %v, expected ErrLocked`, err)
+		}
+	}
+	r, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if data, err := r.GetAll(id); err != nil || string(data) != `record` {
+		t.Fatalf(`got %q and %v`, data, err)
+	}
+	// the locked byte of the writer mustn't prevent reading the start of the datafile
+	reader, err := w.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if data, err := io.ReadAll(io.NewSectionReader(reader, 0, 6)); err != nil || string(data) != `record` {
+		t.Fatalf(`got %q and %v`, data, err)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package aof
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32	= syscall.NewLazyDLL(`kernel32.dll`)
+	lockFileEx	= kernel32.NewProc(`LockFileEx`)
+	unlockFileEx	= kernel32.NewProc(`UnlockFileEx`)
+)
+
+const (
+	lockfileFailImmediately	= 0x1
+	lockfileExclusiveLock	= 0x2
+	errorLockViolation	= 33
+
+	// The locks of LockFileEx are mandatory, so the locked byte is far past any real
+	// offset of the datafile, at 1 << 62, so the other handles can still read the file .
+	lockOffsetHigh	= 1 << 30
+)
+
+// Take an advisory lock on "f" without blocking, shared unless "exclusive" .
+func lockFile(f *os.File, exclusive bool) error {
+	flags := uintptr(lockfileFailImmediately)
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+	ol := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	r, _, err := lockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		if err == syscall.Errno(errorLockViolation) {
+			return ErrLocked
+		}
+		return err
+	}
+	return nil
+}
+
+// Release the advisory lock on "f" .
+func unlockFile(f *os.File) error {
+	ol := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	r, _, err := unlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package aof
+
+import (
+	"context"
+	"errors"
+)
+
+// The max number of records Follow reads per read lock .
+const followBatch = 1024
+
+// Stream the records separated by "sep" like "tail -f" does .
+// The existing records are sent first, then the new ones as they get appended,
+// a trailing record is only sent once its separator is written .
+// The channel is closed once "ctx" is done or the AOF is closed .
+func (this *AOF) Follow(ctx context.Context, sep []byte) (<-chan []byte, error) {
+	if len(sep) == 0 {
+		return nil, errors.New(`aof: Follow requires a separator`)
+	}
+	out := make(chan []byte)
+	wake := make(chan struct{}, 1)
+	remove := this.OnWrite(func(Position) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	// Reopen replaces the done channel, so the one of the AOF being followed is kept
+	this.RLock()
+	done, offset := this.done, this.base
+	this.RUnlock()
+	go func() {
+		defer close(out)
+		defer remove()
+		for {
+			var batch [][]byte
+			err := this.scanFrom(offset, sep, func(data []byte, atEOF bool) error {
+				if atEOF {
+					return nil
+				}
+				batch = append(batch, data)
+				offset += int64(len(data) + len(sep))
+				if len(batch) >= followBatch {
+					return errStop
+				}
+				return nil
+			})
+			if err != nil && err != errStop {
+				return
+			}
+			for _, data := range batch {
+				select {
+				case out <- data:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(batch) >= followBatch {
+				continue
+			}
+			select {
+			case <-wake:
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,43 @@
+package aof
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFollowAcrossReopen(t *testing.T) {
+	a, err := Open(filepath.Join(t.TempDir(), `follow.aof`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+	records, err := a.Follow(ctx, []byte("\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.PutBytes([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-records; string(got) != `first` {
+		t.Fatalf(`got %q, expected "first"`, got)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.PutBytes([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	// closing the AOF ends the stream, reopening it doesn't resume it
+	for range records {
+	}
+	if ctx.Err() != nil {
+		t.Fatal(`the stream didn't end once the AOF was closed`)
+	}
+}
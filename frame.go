@@ -0,0 +1,664 @@
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+// A framed record is a big-endian uint32 length prefix followed by a byte of frame flags
+// and the payload, so records may contain any bytes unlike the separator based Scan, or none
+// at all, up to MaxFrameSize bytes . A checked frame has the frameChecked flag set and its
+// flags are followed by the CRC32 (IEEE) of the payload .
+// A compressed frame has the frameCompressed flag set and an encrypted one has
+// the frameEncrypted flag set, both are always checked . A tombstone is a checked
+// frame with the frameTombstone flag set, whose payload is the id of a deleted record .
+// A timestamped frame has the frameTimestamped flag set and its flags are followed
+// by the big-endian unix nano time it was written at, before the CRC32 if any,
+// which only covers the payload . A frame with user flags has the frameUserFlags flag
+// set and its flags are followed by the byte of flags PutWithFlags stored, after the timestamp if any .
+// A padding frame has the framePadding flag set and a zero filled payload, see Options.Alignment .
+const (
+	frameHeaderSize	= 5
+	frameFlagsOffset	= 4
+	frameChecked	= 1 << 7
+	frameCompressed	= 1 << 6
+	frameEncrypted	= 1 << 5
+	frameTombstone	= 1 << 4
+	frameTimestamped	= 1 << 3
+	frameUserFlags	= 1 << 2
+	framePadding	= 1 << 1
+	frameFlags	= frameChecked | frameCompressed | frameEncrypted | frameTombstone | frameTimestamped | frameUserFlags | framePadding
+
+	// The maximum size of a framed payload .
+	MaxFrameSize	int64	= math.MaxUint32
+)
+
+var (
+	// The frame at the requested offset doesn't fit in the datafile, usually a torn write .
+	ErrTruncatedFrame = errors.New(`aof: truncated frame`)
+
+	// The bytes at the requested offset don't look like a frame .
+	ErrBadFrame = errors.New(`aof: bad frame`)
+
+	// The payload of a checked frame doesn't match its checksum .
+	ErrChecksumMismatch = errors.New(`aof: checksum mismatch`)
+)
+
+// A frame located in the datafile .
+type frame struct {
+	offset	int64
+	header	int64
+	length	int64
+	flags	byte
+	crc	uint32
+	ts	int64
+	user	byte
+}
+
+// Return the offset of the frame payload .
+func (f frame) payload() int64 {
+	return f.offset + f.header
+}
+
+// Return the offset right after the frame .
+func (f frame) end() int64 {
+	return f.offset + f.header + f.length
+}
+
+// Return the size of the header of a frame having "flags" .
+func headerLength(flags byte) int64 {
+	header := int64(frameHeaderSize)
+	if flags & frameTimestamped != 0 {
+		header += 8
+	}
+	if flags & frameUserFlags != 0 {
+		header++
+	}
+	if flags & frameChecked != 0 {
+		header += 4
+	}
+	return header
+}
+
+// Encode "data" into a frame having the flags, the timestamp and the user flags of "meta" .
+func encodeFrame(data []byte, meta frame) ([]byte, error) {
+	if int64(len(data)) > MaxFrameSize {
+		return nil, fmt.Errorf(`aof: frame payload of %d bytes exceeds %d`, len(data), MaxFrameSize)
+	}
+	flags := meta.flags
+	header := headerLength(flags)
+	buf := make([]byte, header + int64(len(data)))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	buf[frameFlagsOffset] = flags
+	next := frameHeaderSize
+	if flags & frameTimestamped != 0 {
+		binary.BigEndian.PutUint64(buf[next:], uint64(meta.ts))
+		next += 8
+	}
+	if flags & frameUserFlags != 0 {
+		buf[next] = meta.user
+	}
+	if flags & frameChecked != 0 {
+		binary.BigEndian.PutUint32(buf[header-4:], crc32.ChecksumIEEE(data))
+	}
+	copy(buf[header:], data)
+	return buf, nil
+}
+
+// Decode the frame header at "offset" read by "r", "limit" is where the frames end .
+func decodeFrame(r io.Reader, offset, limit int64) (frame, error) {
+	f := frame{offset: offset, header: frameHeaderSize}
+	if limit - offset < frameHeaderSize {
+		return f, ErrTruncatedFrame
+	}
+	var hdr [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return f, err
+	}
+	f.length = int64(binary.BigEndian.Uint32(hdr[:]))
+	f.flags = hdr[frameFlagsOffset]
+	if f.flags & ^byte(frameFlags) != 0 {
+		return f, ErrBadFrame
+	}
+	if f.flags & frameTimestamped != 0 {
+		if limit - f.payload() < 8 {
+			return f, ErrTruncatedFrame
+		}
+		var ts [8]byte
+		if _, err := io.ReadFull(r, ts[:]); err != nil {
+			return f, err
+		}
+		f.header += 8
+		f.ts = int64(binary.BigEndian.Uint64(ts[:]))
+	}
+	if f.flags & frameUserFlags != 0 {
+		if limit - f.payload() < 1 {
+			return f, ErrTruncatedFrame
+		}
+		if _, err := io.ReadFull(r, hdr[0:1]); err != nil {
+			return f, err
+		}
+		f.header++
+		f.user = hdr[0]
+	}
+	if f.flags & frameChecked != 0 {
+		if limit - f.payload() < 4 {
+			return f, ErrTruncatedFrame
+		}
+		if _, err := io.ReadFull(r, hdr[0:4]); err != nil {
+			return f, err
+		}
+		f.header += 4
+		f.crc = binary.BigEndian.Uint32(hdr[0:4])
+	}
+	if f.length > limit - f.payload() {
+		return f, ErrTruncatedFrame
+	}
+	return f, nil
+}
+
+// Verify the payload of the frame against its checksum if it has one .
+func (f frame) verify(data []byte) error {
+	if f.flags & frameChecked != 0 && crc32.ChecksumIEEE(data) != f.crc {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// Sequentially read the frames of the datafile .
+type frameScanner struct {
+	file	io.ReaderAt
+	r	*bufio.Reader
+	offset	int64
+	limit	int64
+}
+
+// Create a scanner over the frames between "offset" and the current size,
+// the caller must hold the read lock while using it .
+func (this *AOF) frames(offset int64) *frameScanner {
+	if offset < this.base {
+		offset = this.base
+	}
+	return newFrameScanner(this.file, offset, this.size)
+}
+
+// Create a scanner over the frames of "file" between "offset" and "limit" .
+func newFrameScanner(file io.ReaderAt, offset, limit int64) *frameScanner {
+	return &frameScanner{
+		file:	file,
+		r:	bufio.NewReaderSize(io.NewSectionReader(file, offset, limit - offset), scanBufferSize),
+		offset:	offset,
+		limit:	limit,
+	}
+}
+
+// Read the next frame and its payload, it returns io.EOF after the last frame .
+func (s *frameScanner) next() (frame, []byte, error) {
+	if s.offset >= s.limit {
+		return frame{offset: s.offset}, nil, io.EOF
+	}
+	f, err := decodeFrame(s.r, s.offset, s.limit)
+	if err != nil {
+		return f, nil, err
+	}
+	data := make([]byte, f.length)
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		return f, nil, err
+	}
+	if err := f.verify(data); err != nil {
+		return f, nil, err
+	}
+	s.offset = f.end()
+	return f, data, nil
+}
+
+// Skip the next frame without reading its payload, it returns io.EOF after the last frame .
+// A payload that isn't buffered yet is seeked over rather than read .
+func (s *frameScanner) skip() (frame, error) {
+	if s.offset >= s.limit {
+		return frame{offset: s.offset}, io.EOF
+	}
+	f, err := decodeFrame(s.r, s.offset, s.limit)
+	if err != nil {
+		return f, err
+	}
+	if f.length > int64(s.r.Buffered()) {
+		s.r.Reset(io.NewSectionReader(s.file, f.end(), s.limit - f.end()))
+	} else if _, err := s.r.Discard(int(f.length)); err != nil {
+		return f, err
+	}
+	s.offset = f.end()
+	return f, nil
+}
+
+// Write "data" as a length prefixed frame .
+// It returns the offset of the frame which is what GetFramed expects .
+func (this *AOF) PutFramed(data []byte) (int64, error) {
+	offset, _, err := this.putFrame(data, 0)
+	return offset, err
+}
+
+// Write "data" as a checked frame carrying the CRC32 of the payload .
+// It returns the offset of the frame which is what GetChecked expects .
+func (this *AOF) PutChecked(data []byte) (int64, error) {
+	offset, _, err := this.putFrame(data, frameChecked)
+	return offset, err
+}
+
+// Encode and write a frame, it returns the offset of the frame and the Position of its payload .
+func (this *AOF) putFrame(data []byte, flags byte) (int64, Position, error) {
+	return this.putFrameWith(data, flags, 0)
+}
+
+// Encode and write a frame like putFrame, carrying the user flags "user" if frameUserFlags is set .
+func (this *AOF) putFrameWith(data []byte, flags, user byte) (int64, Position, error) {
+	stored, flags, err := this.encodeRecord(data, flags)
+	if err != nil {
+		return 0, Position{}, err
+	}
+	buf, err := encodeFrame(stored, frame{flags: flags, ts: time.Now().UnixNano(), user: user})
+	if err != nil {
+		return 0, Position{}, err
+	}
+	offset, p, err := this.appendFrame(buf, int64(len(stored)))
+	if err != nil {
+		return 0, Position{}, err
+	}
+	this.notify(p)
+	return offset, p, nil
+}
+
+// Write an encoded frame carrying "length" bytes of payload under the write lock .
+// It returns the offset of the frame and the Position of its payload .
+func (this *AOF) appendFrame(buf []byte, length int64) (int64, Position, error) {
+	if this.opts.GroupCommit > 0 {
+		return this.enqueue(buf, length, true)
+	}
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return 0, Position{}, err
+	}
+	offsets, err := this.writeFrames(buf)
+	if err != nil {
+		return 0, Position{}, err
+	}
+	offset := offsets[0]
+	p := this.position(offset + int64(len(buf)) - length, length)
+	if this.index != nil {
+		this.indexRecord(p)
+	}
+	return offset, p, nil
+}
+
+// Read the payload of the frame at "offset" .
+// The payload of a checked frame is verified against its checksum too .
+func (this *AOF) GetFramed(offset int64) ([]byte, error) {
+	_, data, err := this.frameAt(offset)
+	return data, err
+}
+
+// Read the payload of the checked frame at "offset" .
+// It returns ErrChecksumMismatch if the payload is corrupted and
+// ErrBadFrame if the frame doesn't carry a checksum .
+func (this *AOF) GetChecked(offset int64) ([]byte, error) {
+	f, data, err := this.frameAt(offset)
+	if err == nil && f.flags & frameChecked == 0 {
+		return nil, ErrBadFrame
+	}
+	return data, err
+}
+
+// Whether a well formed frame header, whose payload fits in the datafile, is at "offset" .
+// The payload itself isn't read, use GetChecked to verify it .
+func (this *AOF) Valid(offset int64) bool {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed || offset < 0 || offset >= this.size {
+		return false
+	}
+	_, err := decodeFrame(io.NewSectionReader(this.file, offset, this.size - offset), offset, this.size)
+	return err == nil
+}
+
+// Read the frame at "offset" and its decoded payload .
+func (this *AOF) frameAt(offset int64) (frame, []byte, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return frame{}, nil, ErrClosed
+	}
+	if offset < 0 || offset > this.size {
+		return frame{}, nil, fmt.Errorf(`%w: frame offset %d`, ErrOutOfRange, offset)
+	}
+	this.metrics.reads.Add(1)
+	key := cacheKey{pos: this.position(offset, 0), frame: true}
+	if entry, ok := this.cache.get(key); ok {
+		this.metrics.cacheHits.Add(1)
+		return frame{offset: offset, flags: entry.flags}, append([]byte(nil), entry.data ...), nil
+	}
+	f, data, err := this.frames(offset).next()
+	if err == io.EOF {
+		err = ErrTruncatedFrame
+	}
+	if err == nil {
+		data, err = this.decodeRecord(f.flags, data)
+	}
+	if err == nil && this.cache.fits(int64(len(data))) {
+		this.metrics.cacheMisses.Add(1)
+		this.cache.put(key, f.flags, append([]byte(nil), data ...))
+	}
+	return f, data, err
+}
+
+// Return a reader of the payloads of the framed records back to back, without their
+// headers, so they can be piped into io.Copy, bufio or gzip.NewReader for example .
+// It's bounded by the size at the time of the call, and takes the read lock per frame .
+func (this *AOF) PayloadReader() io.Reader {
+	this.RLock()
+	defer this.RUnlock()
+	return &payloadReader{aof: this, offset: this.base, limit: this.size}
+}
+
+// Read the payloads of the frames between "offset" and "limit" .
+type payloadReader struct {
+	aof	*AOF
+	offset	int64
+	limit	int64
+	pending	[]byte
+}
+
+// Read the pending payload, reading the next frame once it's consumed .
+func (r *payloadReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.offset >= r.limit {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Read and decode the next record frame under the read lock .
+func (r *payloadReader) next() error {
+	r.aof.RLock()
+	defer r.aof.RUnlock()
+	if r.aof.closed {
+		return ErrClosed
+	}
+	if r.limit > r.aof.size {
+		r.limit = r.aof.size
+	}
+	frames := newFrameScanner(r.aof.file, r.offset, r.limit)
+	f, data, err := frames.nextRecord()
+	if err == io.EOF {
+		r.offset = r.limit
+		return nil
+	}
+	if err == nil {
+		data, err = r.aof.decodeRecord(f.flags, data)
+	}
+	if err != nil {
+		return err
+	}
+	r.aof.metrics.reads.Add(1)
+	r.offset, r.pending = f.end(), data
+	return nil
+}
+
+// Walk the frames of the datafile, "fn" receives the offset and the payload of each one .
+// Iteration stops once "fn" returns false, or cleanly before a truncated final frame .
+// This function will hold the read lock till it ends .
+func (this *AOF) ScanFramed(fn func(offset int64, data []byte) bool) {
+	this.eachFrame(0, func(f frame, data []byte) error {
+		if ! fn(f.offset, data) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Walk the frames like ScanFramed, but pass the Position of each payload,
+// which is what GetP expects and whose String is the id Get expects .
+func (this *AOF) ForEachFramed(fn func(pos Position, data []byte) bool) {
+	this.eachFrame(0, func(f frame, data []byte) error {
+		if ! fn(this.position(f.payload(), f.length), data) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Walk the frames like ScanFramed, but pass a reader bounded to the payload of each one
+// instead of reading it into memory, so large records can be streamed wherever needed .
+// The payloads aren't read, so their checksums aren't verified, see VerifyAll,
+// except for compressed or encrypted frames which have to be decoded first .
+// This function will hold the read lock till it ends .
+func (this *AOF) ScanReaders(fn func(r *io.SectionReader) bool) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return
+	}
+	frames := this.frames(0)
+	for {
+		var r *io.SectionReader
+		f, err := frames.skipRecord()
+		if err == nil && f.flags & (frameCompressed | frameEncrypted) != 0 {
+			data := make([]byte, f.length)
+			if _, err = this.readAt(data, f.payload()); err == nil {
+				err = f.verify(data)
+			}
+			if err == nil {
+				data, err = this.decodeRecord(f.flags, data)
+			}
+			r = io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))
+		} else if err == nil {
+			r = io.NewSectionReader(this.file, f.payload(), f.length)
+		}
+		if err != nil || ! fn(r) {
+			return
+		}
+	}
+}
+
+// Walk at most "max" framed records starting at the frame at "offset", all of them if "max" <= 0 .
+// It returns the offset to resume from, which is the end of the last record passed to "fn",
+// and the first error of "fn" or of the frames, so cursor based pagination is a loop over it .
+// This function will hold the read lock till it ends .
+func (this *AOF) WalkFrom(offset int64, max int, fn func(pos Position, data []byte) error) (int64, error) {
+	if offset < 0 || offset > this.Size() {
+		return offset, fmt.Errorf(`%w: offset %d isn't in [0, %d]`, ErrOutOfRange, offset, this.Size())
+	}
+	if offset < this.base {
+		offset = this.base
+	}
+	next, n := offset, 0
+	err := this.eachFrame(offset, func(f frame, data []byte) error {
+		if max > 0 && n >= max {
+			return errStop
+		}
+		if err := fn(this.position(f.payload(), f.length), data); err != nil {
+			return err
+		}
+		next, n = f.end(), n + 1
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return next, err
+}
+
+// Call "fn" for each frame starting at "offset" and its decoded payload under the read lock .
+// It returns nil at the end of the frames, otherwise the first error of "fn" or of the frames .
+func (this *AOF) eachFrame(offset int64, fn func(f frame, data []byte) error) error {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return ErrClosed
+	}
+	frames := this.frames(offset)
+	for {
+		f, data, err := frames.nextRecord()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			data, err = this.decodeRecord(f.flags, data)
+		}
+		if err == nil {
+			err = fn(f, data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Walk all the frames verifying their checksums .
+// It returns the offset of the first corrupt frame and the reason,
+// or the size of the datafile and nil if every frame is intact .
+func (this *AOF) VerifyAll() (int64, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return 0, ErrClosed
+	}
+	frames := this.frames(0)
+	for {
+		f, _, err := frames.next()
+		if err == io.EOF {
+			return f.offset, nil
+		}
+		if err != nil {
+			return f.offset, err
+		}
+	}
+}
+
+// Walk all the frames verifying their length prefixes and checksums without changing anything,
+// as a pre-flight check before serving the datafile, see Repair to fix it .
+// It returns the number of intact records, the offset right after the last intact frame
+// and the first error met, which is nil if the whole datafile is intact .
+func (this *AOF) Validate() (int, int64, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return 0, 0, ErrClosed
+	}
+	records := 0
+	frames := this.frames(0)
+	for {
+		f, _, err := frames.next()
+		if err == io.EOF {
+			return records, f.offset, nil
+		}
+		if err != nil {
+			return records, f.offset, err
+		}
+		if f.flags & (frameTombstone | framePadding) == 0 {
+			records++
+		}
+	}
+}
+
+// Truncate the datafile right after the last intact frame,
+// so a record torn by a crash doesn't break the following reads and writes .
+// It returns the number of trailing bytes that were discarded .
+func (this *AOF) Repair() (int64, error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return 0, err
+	}
+	frames := this.frames(0)
+	for {
+		f, _, err := frames.next()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err == ErrTruncatedFrame || err == ErrBadFrame || err == ErrChecksumMismatch {
+			discarded := this.size - f.offset
+			if err := this.truncate(f.offset); err != nil {
+				return 0, err
+			}
+			this.trimIndex(f.offset)
+			if this.opts.Logger != nil {
+				this.log(`repair`, map[string]any{`offset`: f.offset, `discarded`: discarded})
+			}
+			if this.deleted != nil {
+				this.loadTombstones()
+			}
+			return discarded, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Load a framed backup from "r" into the empty datafile like ReadFrom,
+// but every frame is validated on the way and a corrupt stream is refused .
+func (this *AOF) RestoreFramed(r io.Reader) (int64, error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return 0, err
+	}
+	if this.size != this.base {
+		return 0, fmt.Errorf(`aof: can't restore into a non empty datafile`)
+	}
+	fail := func(err error) (int64, error) {
+		this.truncate(this.base)
+		return 0, err
+	}
+	br := bufio.NewReaderSize(r, scanBufferSize)
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			break
+		}
+		f, err := decodeFrame(br, this.size, math.MaxInt64)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrTruncatedFrame
+		}
+		if err != nil {
+			return fail(err)
+		}
+		data := make([]byte, f.length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return fail(ErrTruncatedFrame)
+		}
+		if err := f.verify(data); err != nil {
+			return fail(err)
+		}
+		buf, _ := encodeFrame(data, f)
+		n, err := this.file.Write(buf)
+		this.size += int64(n)
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if err := this.fsync(); err != nil {
+		return fail(err)
+	}
+	this.grew()
+	if this.index != nil {
+		this.buildIndex()
+	}
+	if this.deleted != nil {
+		this.loadTombstones()
+	}
+	return this.size, nil
+}
@@ -0,0 +1,122 @@
+package aof
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFramedRecordLargerThan16MiB(t *testing.T) {
+	a, err := OpenWithOptions(filepath.Join(t.TempDir(), `large.aof`), 0644, Options{Timestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	data := bytes.Repeat([]byte{'x'}, 17 << 20)
+	id, err := a.Put(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.GetAll(id); err != nil || ! bytes.Equal(got, data) {
+		t.Fatalf(`got %d bytes and %v, expected %d bytes`, len(got), err, len(data))
+	}
+	if n, _, err := a.Validate(); err != nil || n != 1 {
+		t.Fatalf(`got %d records and %v, expected 1 record`, n, err)
+	}
+}
+
+func TestRepairTrimsIndex(t *testing.T) {
+	for _, stride := range []int{1, 2} {
+		path := filepath.Join(t.TempDir(), `repair.aof`)
+		a, err := OpenWithOptions(path, 0644, Options{BuildIndex: true, IndexStride: stride})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var second int64
+		for i, record := range []string{`one`, `two`, `three`} {
+			offset, err := a.PutChecked([]byte(record))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i == 1 {
+				second = offset
+			}
+		}
+		// flip a byte of the payload of the second record behind the AOF's back
+		f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteAt([]byte{'X'}, second + headerLength(frameChecked)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if _, err := a.Repair(); err != nil {
+			t.Fatal(err)
+		}
+		if a.Size() != second {
+			t.Fatalf(`the size is %d, expected %d`, a.Size(), second)
+		}
+		if n, err := a.Count(); err != nil || n != 1 {
+			t.Fatalf(`counted %d records and got %v, expected 1`, n, err)
+		}
+		if _, err := a.At(1); err == nil {
+			t.Fatal(`At(1) should fail once the second record was repaired away`)
+		}
+		if _, err := a.At(0); err != nil {
+			t.Fatal(err)
+		}
+		a.Close()
+	}
+}
+
+func TestEmptyRecords(t *testing.T) {
+	a, err := OpenWithOptions(filepath.Join(t.TempDir(), `empty.aof`), 0644, Options{BuildIndex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	for _, record := range []string{`a`, ``, ``, `b`} {
+		if _, err := a.PutChecked([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	a.ScanFramed(func(offset int64, data []byte) bool {
+		got = append(got, string(data))
+		return true
+	})
+	if len(got) != 4 || got[0] != `a` || got[1] != `` || got[2] != `` || got[3] != `b` {
+		t.Fatalf(`got the records %q`, got)
+	}
+	if n, _, err := a.Validate(); err != nil || n != 4 {
+		t.Fatalf(`got %d records and %v, expected 4`, n, err)
+	}
+	if r, err := a.At(1); err != nil || r.Size() != 0 {
+		t.Fatalf(`At(1) got %v, expected an empty record`, err)
+	}
+	if err := a.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	// an empty unframed record is a valid presence marker too
+	id, err := a.Put(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := a.Get(id)
+	if r == nil || r.Size() != 0 {
+		t.Fatalf(`got %v, expected an empty reader`, r)
+	}
+	if _, err := a.PutBytes([]byte("x\n\n\ny\n")); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	a.Scan([]byte("\n"), func(data []byte, atEOF bool) bool {
+		got = append(got, string(data))
+		return true
+	})
+	if len(got) != 4 || got[0] != `x` || got[1] != `` || got[2] != `` || got[3] != `y` {
+		t.Fatalf(`got the records %q`, got)
+	}
+}
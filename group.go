@@ -0,0 +1,106 @@
+package aof
+
+// A record waiting in the group commit queue, its encoded bytes are "buf" whose
+// last "length" bytes are the payload, "framed" records are indexed .
+type groupWrite struct {
+	buf	[]byte
+	length	int64
+	framed	bool
+	reply	chan groupReply
+}
+
+// What the committer replies to a queued write .
+type groupReply struct {
+	offset	int64
+	pos	Position
+	err	error
+}
+
+// Start the committer goroutine of Options.GroupCommit .
+func (this *AOF) startGroupCommit() {
+	this.queue = make(chan groupWrite, this.opts.GroupCommit)
+	this.committed = make(chan struct{})
+	go this.commitGroups(this.queue, this.done, this.committed)
+}
+
+// Drain the queue into groups till the AOF is closed, then close "committed"
+// so the writers still waiting know their records won't be committed .
+func (this *AOF) commitGroups(queue chan groupWrite, done, committed chan struct{}) {
+	defer close(committed)
+	for {
+		select {
+		case <-done:
+			return
+		case w := <-queue:
+			group := []groupWrite{w}
+		drain:
+			for len(group) < cap(queue) {
+				select {
+				case w := <-queue:
+					group = append(group, w)
+				default:
+					break drain
+				}
+			}
+			this.commitGroup(group)
+		}
+	}
+}
+
+// Write the records of "group" at once, synced once per the sync policy, and reply
+// to each writer . The group is all or nothing like PutBatch .
+func (this *AOF) commitGroup(group []groupWrite) {
+	this.Lock()
+	bufs := make([][]byte, len(group))
+	framed := make([]bool, len(group))
+	for i, w := range group {
+		bufs[i], framed[i] = w.buf, w.framed
+	}
+	err := this.writable()
+	var offsets []int64
+	if err == nil {
+		offsets, err = this.writeAligned(bufs, framed)
+	}
+	replies := make([]groupReply, len(group))
+	for i, w := range group {
+		if err != nil {
+			replies[i].err = err
+			continue
+		}
+		replies[i].offset = offsets[i]
+		replies[i].pos = this.position(offsets[i] + int64(len(w.buf)) - w.length, w.length)
+		if w.framed && this.index != nil {
+			this.indexRecord(replies[i].pos)
+		}
+	}
+	this.Unlock()
+	for i, w := range group {
+		w.reply <- replies[i]
+	}
+}
+
+// Queue "buf" to the committer and wait till it's committed .
+// It returns the offset of "buf" and the Position of its last "length" bytes .
+func (this *AOF) enqueue(buf []byte, length int64, framed bool) (int64, Position, error) {
+	this.RLock()
+	queue, done, committed := this.queue, this.done, this.committed
+	this.RUnlock()
+	w := groupWrite{buf, length, framed, make(chan groupReply, 1)}
+	select {
+	case queue <- w:
+	case <-done:
+		return 0, Position{}, ErrClosed
+	}
+	select {
+	case r := <-w.reply:
+		return r.offset, r.pos, r.err
+	case <-committed:
+	}
+	// the committer may have replied right before it stopped
+	select {
+	case r := <-w.reply:
+		return r.offset, r.pos, r.err
+	default:
+		return 0, Position{}, ErrClosed
+	}
+}
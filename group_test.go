@@ -0,0 +1,34 @@
+package aof
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// 16 writers per CPU putting small records with SyncAlways, so each write waits for an fsync
+func benchmarkConcurrentPuts(b *testing.B, opts Options) {
+	a, err := OpenWithOptions(filepath.Join(b.TempDir(), `group.aof`), 0644, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer a.Close()
+	data := make([]byte, 128)
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := a.PutBytes(data); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkPutLockPerWrite(b *testing.B) {
+	benchmarkConcurrentPuts(b, Options{Sync: SyncAlways})
+}
+
+func BenchmarkPutGroupCommit(b *testing.B) {
+	benchmarkConcurrentPuts(b, Options{Sync: SyncAlways, GroupCommit: 64})
+}
@@ -0,0 +1,132 @@
+package aof
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// The header optionally written at the start of the datafile (and of every segment),
+// the magic, the format version and the flags describing how the file was created .
+const (
+	headerMagic	= "\x89AOF\r\n\x1a\n"
+	headerVersion	= 1
+	headerSize	= len(headerMagic) + 2
+)
+
+// The header flags, the low 2 bits hold the Framing the file was created with .
+const (
+	headerFraming	= 1 << 2 - 1
+	headerCompressed	= 1 << 2
+	headerEncrypted	= 1 << 3
+	headerTimestamps	= 1 << 4
+)
+
+// Returned by Open when Options.Header is set but the datafile doesn't start with the magic .
+var ErrBadMagic = errors.New(`aof: not an AOF datafile`)
+
+// Returned by Open when the header of the datafile carries an unknown format version .
+var ErrUnsupportedVersion = errors.New(`aof: unsupported format version`)
+
+// Encode the header of a new file according to the options .
+func (this *AOF) header() []byte {
+	flags := byte(this.opts.Framing) & headerFraming
+	if this.opts.Compression != NoCompression {
+		flags |= headerCompressed
+	}
+	if this.aead != nil {
+		flags |= headerEncrypted
+	}
+	if this.opts.Timestamps {
+		flags |= headerTimestamps
+	}
+	return append([]byte(headerMagic), headerVersion, flags)
+}
+
+// Read the header of "file" holding "size" bytes, it returns the size of the header,
+// which is 0 if the file doesn't start with the magic, and the header flags .
+func readHeader(file io.ReaderAt, size int64) (int64, byte, error) {
+	if size < int64(headerSize) {
+		return 0, 0, nil
+	}
+	var hdr [headerSize]byte
+	if _, err := file.ReadAt(hdr[:], 0); err != nil {
+		return 0, 0, err
+	}
+	if ! bytes.Equal(hdr[0:len(headerMagic)], []byte(headerMagic)) {
+		return 0, 0, nil
+	}
+	if hdr[len(headerMagic)] != headerVersion {
+		return 0, 0, ErrUnsupportedVersion
+	}
+	return int64(headerSize), hdr[len(headerMagic) + 1], nil
+}
+
+// Detect the header of the active file, or write it if the file is new and Options.Header is set .
+// The records start right after the header, so it's where the scans start from .
+func (this *AOF) openHeader() error {
+	if this.size == 0 && this.opts.Header && ! this.readOnly {
+		return this.writeHeader(this.file)
+	}
+	base, flags, err := readHeader(this.file, this.size)
+	if err != nil {
+		return err
+	}
+	if base == 0 && this.opts.Header {
+		return ErrBadMagic
+	}
+	this.base, this.flags = base, flags
+	return nil
+}
+
+// Write the header to the empty "file", the caller must hold the write lock if it's the active file .
+func (this *AOF) writeHeader(file datafile) error {
+	hdr := this.header()
+	if _, err := file.Write(hdr); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if file == this.file {
+		this.size = int64(len(hdr))
+	}
+	this.base, this.flags = int64(len(hdr)), hdr[len(hdr)-1]
+	return nil
+}
+
+// Format describes how a datafile was created, as recorded by its header .
+// All the integers of the header and of the frames are big-endian, so the datafiles
+// move between machines of any byte order .
+type Format struct {
+	// The format version .
+	Version	int
+
+	// The Framing the datafile was created with .
+	Framing	Framing
+
+	// Whether the records are compressed, encrypted or timestamped .
+	Compressed	bool
+	Encrypted	bool
+	Timestamps	bool
+}
+
+// Return the Format read from the header of the datafile,
+// it returns ErrBadMagic if the datafile doesn't have a header, see Options.Header .
+func (this *AOF) FormatInfo() (Format, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return Format{}, ErrClosed
+	}
+	if this.base == 0 {
+		return Format{}, ErrBadMagic
+	}
+	return Format{
+		Version:	headerVersion,
+		Framing:	Framing(this.flags & headerFraming),
+		Compressed:	this.flags & headerCompressed != 0,
+		Encrypted:	this.flags & headerEncrypted != 0,
+		Timestamps:	this.flags & headerTimestamps != 0,
+	}, nil
+}
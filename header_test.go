@@ -0,0 +1,50 @@
+package aof
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOnDiskLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `layout.aof`)
+	a, err := OpenWithOptions(path, 0644, Options{Header: true, Framing: FramingChecked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if _, err := a.PutChecked([]byte(`abc`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.PutWithFlags([]byte(`de`), 0x5a); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(0, 0x0102030405060708)
+	putTimed(t, a, `f`, ts)
+	want := []byte{
+		// the magic, the version and the header flags holding FramingChecked
+		0x89, 'A', 'O', 'F', '\r', '\n', 0x1a, '\n', 1, 2,
+		// the length, the flags frameChecked, the CRC32 of "abc" and the payload
+		0, 0, 0, 3, 0x80, 0x35, 0x24, 0x41, 0xc2, 'a', 'b', 'c',
+		// the length, the flags frameChecked | frameUserFlags, the user flags, the CRC32 and the payload
+		0, 0, 0, 2, 0x84, 0x5a, 0x7d, 0x90, 0x29, 0x8b, 'd', 'e',
+		// the length, the flags frameChecked | frameTimestamped, the timestamp, the CRC32 and the payload
+		0, 0, 0, 1, 0x88, 1, 2, 3, 4, 5, 6, 7, 8, 0x76, 0xd3, 0x2b, 0xe0, 'f',
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ! bytes.Equal(got, want) {
+		t.Fatalf("the datafile holds\n% x\nexpected\n% x", got, want)
+	}
+	format, err := a.FormatInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != (Format{Version: 1, Framing: FramingChecked}) {
+		t.Fatalf(`the format is %+v`, format)
+	}
+}
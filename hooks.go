@@ -0,0 +1,46 @@
+package aof
+
+import (
+	"sync"
+)
+
+// The registered write callbacks .
+type hooks struct {
+	sync.Mutex
+	next	int
+	fns	map[int]func(Position)
+}
+
+// Register "fn" to be called with the Position of every record appended successfully .
+// It returns a function that removes the registration .
+// Callbacks run outside of the AOF lock, right after the write, in the writing goroutine,
+// so they must not block and must not call back into the AOF .
+func (this *AOF) OnWrite(fn func(pos Position)) (remove func()) {
+	this.hooks.Lock()
+	defer this.hooks.Unlock()
+	if this.hooks.fns == nil {
+		this.hooks.fns = map[int]func(Position){}
+	}
+	id := this.hooks.next
+	this.hooks.next++
+	this.hooks.fns[id] = fn
+	return func() {
+		this.hooks.Lock()
+		defer this.hooks.Unlock()
+		delete(this.hooks.fns, id)
+	}
+}
+
+// Count the record at "p" and call the registered write callbacks with it .
+func (this *AOF) notify(p Position) {
+	this.metrics.records.Add(1)
+	this.hooks.Lock()
+	fns := make([]func(Position), 0, len(this.hooks.fns))
+	for _, fn := range this.hooks.fns {
+		fns = append(fns, fn)
+	}
+	this.hooks.Unlock()
+	for _, fn := range fns {
+		fn(p)
+	}
+}
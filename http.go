@@ -0,0 +1,60 @@
+package aof
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Serve the AOF over HTTP, "POST /" appends the request body and responds with
+// its id, "GET /{id}" streams the record of the id .
+// Malformed ids get 400 and ids outside of the datafile get 404 .
+func (this *AOF) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, `/`)
+		switch {
+		case r.Method == http.MethodPost && id == ``:
+			this.servePut(w, r)
+		case (r.Method == http.MethodGet || r.Method == http.MethodHead) && id != ``:
+			this.serveGet(w, r, id)
+		case id == ``:
+			w.Header().Set(`Allow`, `POST`)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		default:
+			w.Header().Set(`Allow`, `GET, HEAD`)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// Append the request body, responding with its id .
+func (this *AOF) servePut(w http.ResponseWriter, r *http.Request) {
+	id, err := this.Put(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(`Content-Type`, `text/plain; charset=utf-8`)
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, id)
+}
+
+// Stream the record of "id" .
+func (this *AOF) serveGet(w http.ResponseWriter, r *http.Request, id string) {
+	if _, err := ParsePosition(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ! this.Exists(id) {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := this.GetReader(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(`Content-Type`, `application/octet-stream`)
+	http.ServeContent(w, r, ``, time.Time{}, data)
+}
@@ -0,0 +1,197 @@
+package aof
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// Index the payloads of the well formed frames of every segment, up to the first bad one of each,
+// the caller must hold the write lock .
+func (this *AOF) buildIndex() {
+	this.index, this.indexed = []Position{}, 0
+	nums := []int{}
+	for _, seg := range this.segments {
+		nums = append(nums, seg.num)
+	}
+	for _, n := range append(nums, this.segment) {
+		file, size, _ := this.segmentFile(n)
+		base, _, err := readHeader(file, size)
+		if err != nil {
+			continue
+		}
+		frames := newFrameScanner(file, base, size)
+		for {
+			f, err := frames.skipRecord()
+			if err != nil {
+				break
+			}
+			this.indexRecord(Position{Offset: f.payload(), Length: f.length, Segment: n})
+		}
+	}
+}
+
+// Return the sampling stride of the index, 1 if it isn't sparse .
+func (this *AOF) indexStride() int {
+	if this.opts.IndexStride > 1 {
+		return this.opts.IndexStride
+	}
+	return 1
+}
+
+// Count the record at "p" in the index, which keeps every Options.IndexStride-th one only .
+// The caller must hold the write lock .
+func (this *AOF) indexRecord(p Position) {
+	if this.indexed % this.indexStride() == 0 {
+		this.index = append(this.index, p)
+	}
+	this.indexed++
+}
+
+// Return the memory held by the index, in bytes, 0 if it isn't enabled .
+func (this *AOF) IndexMemoryBytes() int64 {
+	this.RLock()
+	defer this.RUnlock()
+	return int64(cap(this.index)) * int64(unsafe.Sizeof(Position{}))
+}
+
+// Return the Positions of the payloads of the framed records, tombstones excluded,
+// walking the frame headers only, so it's much cheaper than ForEachFramed for large
+// payloads, the checksums aren't verified though . It's bounded by the size at the time
+// of the call, a truncated or bad frame stops the walk with the error that was hit,
+// along with the Positions found before it .
+func (this *AOF) Positions() ([]Position, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return nil, ErrClosed
+	}
+	return this.positions()
+}
+
+// Walk the frame headers collecting the Positions, the caller must hold the read lock .
+func (this *AOF) positions() ([]Position, error) {
+	positions := []Position{}
+	frames := this.frames(0)
+	for {
+		f, err := frames.skipRecord()
+		if err == io.EOF {
+			return positions, nil
+		} else if err != nil {
+			return positions, err
+		}
+		positions = append(positions, this.position(f.payload(), f.length))
+	}
+}
+
+// Read the payload of the i-th framed record, it requires Options.BuildIndex .
+func (this *AOF) At(i int) (*io.SectionReader, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return nil, ErrClosed
+	}
+	if this.index == nil {
+		return nil, fmt.Errorf(`aof: the index isn't enabled`)
+	}
+	if i < 0 || i >= this.indexed {
+		return nil, fmt.Errorf(`%w: record %d isn't in [0, %d)`, ErrOutOfRange, i, this.indexed)
+	}
+	stride := this.indexStride()
+	p, err := this.walkIndex(this.index[i / stride], i % stride)
+	if err != nil {
+		return nil, err
+	}
+	file, _, err := this.segmentFile(p.Segment)
+	if err != nil {
+		return nil, err
+	}
+	this.metrics.reads.Add(1)
+	return this.section(file, p)
+}
+
+// Return the Position of the n-th record following the sampled record at "p" of a sparse index,
+// by walking the frame headers after it, into the next segments if need be .
+func (this *AOF) walkIndex(p Position, n int) (Position, error) {
+	for num, offset := p.Segment, p.Offset + p.Length; n > 0; {
+		file, size, err := this.segmentFile(num)
+		if err != nil {
+			return Position{}, err
+		}
+		frames := newFrameScanner(file, offset, size)
+		for n > 0 {
+			f, err := frames.skipRecord()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return Position{}, err
+			}
+			p, n = Position{Offset: f.payload(), Length: f.length, Segment: num}, n - 1
+		}
+		if n > 0 {
+			num++
+			if file, size, err = this.segmentFile(num); err != nil {
+				return Position{}, err
+			}
+			if offset, _, err = readHeader(file, size); err != nil {
+				return Position{}, err
+			}
+		}
+	}
+	return p, nil
+}
+
+// Return the number of framed records, straight from the index if it's built,
+// otherwise by walking the frame headers .
+// A truncated or bad frame stops the count with the error that was hit .
+func (this *AOF) Count() (int, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return 0, ErrClosed
+	}
+	return this.count()
+}
+
+// Count the framed records starting at the frame at "offset" by walking their headers,
+// so a poller knowing the last seen offset counts only the new records .
+// It returns the count and the offset the records end at, to count from the next time,
+// both bounded by the size at the time of the call .
+func (this *AOF) CountFrom(offset int64) (int, int64, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return 0, offset, ErrClosed
+	}
+	if offset < 0 || offset > this.size {
+		return 0, offset, fmt.Errorf(`%w: offset %d isn't in [0, %d]`, ErrOutOfRange, offset, this.size)
+	}
+	count := 0
+	frames := this.frames(offset)
+	for {
+		f, err := frames.skipRecord()
+		if err == io.EOF {
+			return count, frames.offset, nil
+		} else if err != nil {
+			return count, f.offset, err
+		}
+		count++
+	}
+}
+
+// Count the framed records, the caller must hold the read lock .
+func (this *AOF) count() (int, error) {
+	if this.index != nil {
+		return this.indexed, nil
+	}
+	count := 0
+	frames := this.frames(0)
+	for {
+		if _, err := frames.skipRecord(); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
@@ -0,0 +1,49 @@
+package aof
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestSparseIndexAt(t *testing.T) {
+	var dense int64
+	for _, stride := range []int{1, 3, 7} {
+		a, err := OpenWithOptions(filepath.Join(t.TempDir(), `index.aof`), 0644, Options{BuildIndex: true, IndexStride: stride, MaxSegmentBytes: 256})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records := 50
+		for i := 0; i < records; i++ {
+			if _, err := a.PutChecked([]byte(fmt.Sprintf(`record %d`, i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for pass := 0; pass < 2; pass++ {
+			for i := 0; i < records; i++ {
+				r, err := a.At(i)
+				if err != nil {
+					t.Fatalf(`stride %d: At(%d): %v`, stride, i, err)
+				}
+				if data, _ := io.ReadAll(r); string(data) != fmt.Sprintf(`record %d`, i) {
+					t.Fatalf(`stride %d: At(%d) is %q`, stride, i, data)
+				}
+			}
+			if _, err := a.At(records); err == nil {
+				t.Fatalf(`stride %d: At(%d) should be out of range`, stride, records)
+			}
+			// the index built at Open must answer the same
+			a.Close()
+			if a, err = OpenWithOptions(a.Path(), 0644, Options{BuildIndex: true, IndexStride: stride, MaxSegmentBytes: 256}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if stride == 1 {
+			dense = a.IndexMemoryBytes()
+		} else if a.IndexMemoryBytes() >= dense {
+			t.Fatalf(`stride %d: the index takes %d bytes, as much as the dense one`, stride, a.IndexMemoryBytes())
+		}
+		a.Close()
+	}
+}
@@ -0,0 +1,27 @@
+//go:build go1.23
+
+package aof
+
+import (
+	"iter"
+)
+
+// Return an iterator over the records separated by "sep", like Scan, so they can be
+// ranged over: for data := range aof.All(sep) . The read lock is held during the loop
+// and released once it ends, a break included, so its body must not write to the AOF .
+func (this *AOF) All(sep []byte) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		this.Scan(sep, func(data []byte, atEOF bool) bool {
+			return yield(data)
+		})
+	}
+}
+
+// Return an iterator over the framed records and their Positions, like ForEachFramed,
+// so they can be ranged over: for pos, data := range aof.AllFramed() . The read lock is held
+// during the loop and released once it ends, a break included, so its body must not write to the AOF .
+func (this *AOF) AllFramed() iter.Seq2[Position, []byte] {
+	return func(yield func(Position, []byte) bool) {
+		this.ForEachFramed(yield)
+	}
+}
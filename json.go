@@ -0,0 +1,46 @@
+package aof
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Marshal "v" as JSON and append it followed by a newline, so the datafile
+// stays newline delimited JSON that can be scanned using Scan([]byte("\n"), ...) .
+// The returned id covers the JSON document without the newline . With Options.Compression,
+// EncryptionKey or Timestamps, the document is written as a framed record like PutBytes,
+// without the newline, GetJSON reads it back the same .
+func (this *AOF) PutJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ``, err
+	}
+	if this.transforms() {
+		return this.PutBytes(data)
+	}
+	p, err := this.putBytes(append(data, '\n'))
+	if err != nil {
+		return ``, err
+	}
+	p.Length--
+	this.notify(p)
+	return p.String(), nil
+}
+
+// Read the record "id" and unmarshal it as JSON into "v" .
+// Read errors are returned as is, while decode errors are wrapped .
+func (this *AOF) GetJSON(id string, v any) error {
+	r, err := this.GetReader(id)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf(`aof: decoding the JSON record %q: %w`, id, err)
+	}
+	return nil
+}
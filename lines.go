@@ -0,0 +1,40 @@
+package aof
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The separator of the line oriented records .
+var newline = []byte("\n")
+
+// Append "s" followed by a newline, so the datafile stays a plain text log
+// readable by cat and tail, and scannable by Lines .
+// A string containing a newline is refused since it would read back as several lines,
+// the returned Position covers the line without the newline . The lines are stored as is,
+// so it fails with Options.Compression, EncryptionKey or Timestamps .
+func (this *AOF) PutLine(s string) (Position, error) {
+	if err := this.plain(`PutLine`); err != nil {
+		return Position{}, err
+	}
+	if strings.Contains(s, "\n") {
+		return Position{}, fmt.Errorf(`aof: a line can't contain a newline`)
+	}
+	p, err := this.putBytes(append([]byte(s), '\n'))
+	if err != nil {
+		return Position{}, err
+	}
+	p.Length--
+	this.notify(p)
+	return p, nil
+}
+
+// Scan the datafile line by line, the lines are passed without their newline
+// and the trailing data that isn't terminated by one is passed as the last line .
+// Iteration stops once "fn" returns false .
+// This function will hold the read lock till it ends .
+func (this *AOF) Lines(fn func(line string) bool) {
+	this.Scan(newline, func(data []byte, atEOF bool) bool {
+		return fn(string(data))
+	})
+}
@@ -0,0 +1,33 @@
+package aof
+
+import (
+	"time"
+)
+
+// The sync latency over which a "slow_sync" event is logged when Options.SlowSync isn't set .
+const defaultSlowSync = 100 * time.Millisecond
+
+// Report the notable "event" to Options.Logger, the callers check it's set
+// before building the fields so there is no cost without a logger .
+func (this *AOF) log(event string, fields map[string]any) {
+	fields[`path`] = this.path
+	this.opts.Logger(event, fields)
+}
+
+// Fsync "file" counting it, and log it if it took longer than the slow sync threshold .
+func (this *AOF) syncFile(file datafile) error {
+	this.metrics.fsyncs.Add(1)
+	if this.opts.Logger == nil {
+		return this.within(file.Sync)
+	}
+	start := time.Now()
+	err := this.within(file.Sync)
+	threshold := this.opts.SlowSync
+	if threshold <= 0 {
+		threshold = defaultSlowSync
+	}
+	if took := time.Since(start); took > threshold {
+		this.log(`slow_sync`, map[string]any{`file`: file.Name(), `duration`: took, `error`: err})
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package aof
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// The part of *os.File the AOF needs from its active file, so the datafile
+// may be kept in memory too, see OpenMemory .
+type datafile interface {
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+	Sync() error
+	Stat() (os.FileInfo, error)
+	Name() string
+	Close() error
+}
+
+// Open an AOF held in memory, for the tests and the scratch logs, which behaves like
+// one backed by a file but isn't durable at all, its records are gone once it's closed .
+// It has no path, so Reopen, Compact, Healthy and the other methods working by path fail .
+func OpenMemory() *AOF {
+	this := new(AOF)
+	this.file = new(memFile)
+	this.done = make(chan struct{})
+	this.unlocked = true
+	return this
+}
+
+// An in memory datafile, the writes are appended like with O_APPEND whatever the offset .
+type memFile struct {
+	sync.RWMutex
+	data	[]byte
+	offset	int64
+	closed	bool
+}
+
+func (m *memFile) ReadAt(p []byte, off int64) (int, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, os.ErrInvalid
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	m.data = append(m.data, p ...)
+	m.offset = int64(len(m.data))
+	return len(p), nil
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += m.offset
+	case io.SeekEnd:
+		offset += int64(len(m.data))
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+	m.offset = offset
+	return offset, nil
+}
+
+func (m *memFile) Truncate(size int64) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.closed {
+		return os.ErrClosed
+	}
+	if size < 0 {
+		return os.ErrInvalid
+	}
+	if size <= int64(len(m.data)) {
+		m.data = m.data[0:size:size]
+	} else {
+		m.data = append(m.data, make([]byte, size - int64(len(m.data))) ...)
+	}
+	return nil
+}
+
+// There is nothing to sync, the data only lives in memory .
+func (m *memFile) Sync() error {
+	return nil
+}
+
+func (m *memFile) Stat() (os.FileInfo, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return memInfo(len(m.data)), nil
+}
+
+func (m *memFile) Name() string {
+	return `memory`
+}
+
+func (m *memFile) Close() error {
+	m.Lock()
+	defer m.Unlock()
+	if m.closed {
+		return os.ErrClosed
+	}
+	m.closed, m.data = true, nil
+	return nil
+}
+
+// The os.FileInfo of a memFile holding the given number of bytes .
+type memInfo int64
+
+func (i memInfo) Name() string		{ return `memory` }
+func (i memInfo) Size() int64		{ return int64(i) }
+func (i memInfo) Mode() os.FileMode	{ return 0600 }
+func (i memInfo) ModTime() time.Time	{ return time.Time{} }
+func (i memInfo) IsDir() bool		{ return false }
+func (i memInfo) Sys() any		{ return nil }
@@ -0,0 +1,107 @@
+package aof
+
+import (
+	"fmt"
+	"io"
+)
+
+// The size of the chunks of frames Merge writes at once .
+const mergeChunkSize = 1 << 20
+
+// Append all the framed records of "srcs", in order, to "dst", with their frame flags
+// and timestamps but new ids relative to "dst" . The sources are read locked one after
+// the other while "dst" is write locked during the whole merge, on failure "dst" is
+// truncated back unless a segment was started meanwhile .
+// The tombstones and the deleted records of the sources aren't merged, and the encrypted
+// records are copied as is so "dst" needs the same Options.EncryptionKey to read them .
+func Merge(dst *AOF, srcs ...*AOF) error {
+	for _, src := range srcs {
+		if src == dst {
+			return fmt.Errorf(`aof: can't merge a datafile into itself`)
+		}
+	}
+	positions, err := dst.merge(srcs)
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		dst.notify(p)
+	}
+	return nil
+}
+
+// Append the records of "srcs" under the write lock .
+func (this *AOF) merge(srcs []*AOF) ([]Position, error) {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return nil, err
+	}
+	start, segment := this.size, this.segment
+	positions := []Position{}
+	for _, src := range srcs {
+		if err := this.mergeFrom(src, &positions); err != nil {
+			if this.segment == segment {
+				this.truncate(start)
+			}
+			return nil, err
+		}
+	}
+	if this.index != nil {
+		for _, p := range positions {
+			this.indexRecord(p)
+		}
+	}
+	return positions, nil
+}
+
+// Append the records of "src" under its read lock, adding their Positions to "positions" .
+func (this *AOF) mergeFrom(src *AOF, positions *[]Position) error {
+	src.RLock()
+	defer src.RUnlock()
+	if src.closed {
+		return ErrClosed
+	}
+	var bufs [][]byte
+	var size int
+	var headers, lengths []int64
+	flush := func() error {
+		if len(bufs) == 0 {
+			return nil
+		}
+		offsets, err := this.writeFrames(bufs ...)
+		if err != nil {
+			return err
+		}
+		for i := range headers {
+			*positions = append(*positions, this.position(offsets[i] + headers[i], lengths[i]))
+		}
+		bufs, size, headers, lengths = bufs[:0], 0, headers[:0], lengths[:0]
+		return nil
+	}
+	frames := src.frames(0)
+	for {
+		f, data, err := frames.nextRecord()
+		if err != nil {
+			if err == io.EOF {
+				return flush()
+			}
+			return err
+		}
+		if src.isDeleted(f) {
+			continue
+		}
+		frame, err := encodeFrame(data, f)
+		if err != nil {
+			return err
+		}
+		if size + len(frame) > mergeChunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		bufs, size = append(bufs, frame), size + len(frame)
+		headers = append(headers, int64(len(frame) - len(data)))
+		lengths = append(lengths, int64(len(data)))
+	}
+}
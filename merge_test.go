@@ -0,0 +1,53 @@
+package aof
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMergeOppositeDirections(t *testing.T) {
+	dir := t.TempDir()
+	var aofs [2]*AOF
+	for i := range aofs {
+		a, err := Open(filepath.Join(dir, string(rune('a' + i)) + `.aof`), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer a.Close()
+		if _, err := a.PutChecked([]byte(`record`)); err != nil {
+			t.Fatal(err)
+		}
+		aofs[i] = a
+	}
+	const rounds = 50
+	var wg sync.WaitGroup
+	for i := range aofs {
+		wg.Add(1)
+		go func(dst, src *AOF) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if err := Merge(dst, src); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(aofs[i], aofs[1 - i])
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal(`the merges in opposite directions deadlocked`)
+	}
+	for _, a := range aofs {
+		if _, _, err := a.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
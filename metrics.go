@@ -0,0 +1,59 @@
+package aof
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// The counters behind Metrics, they are updated atomically .
+type metrics struct {
+	written	atomic.Int64
+	records	atomic.Int64
+	reads	atomic.Int64
+	fsyncs	atomic.Int64
+	cacheHits	atomic.Int64
+	cacheMisses	atomic.Int64
+}
+
+// Metrics is a snapshot of the activity of an AOF since it was opened .
+type Metrics struct {
+	BytesWritten	int64
+	RecordsWritten	int64
+	Reads		int64
+	Fsyncs		int64
+	CacheHits	int64
+	CacheMisses	int64
+	Size		int64
+}
+
+// Return a snapshot of the Metrics of the AOF .
+func (this *AOF) Metrics() Metrics {
+	this.RLock()
+	size := this.size
+	this.RUnlock()
+	return Metrics{
+		BytesWritten:	this.metrics.written.Load(),
+		RecordsWritten:	this.metrics.records.Load(),
+		Reads:		this.metrics.reads.Load(),
+		Fsyncs:		this.metrics.fsyncs.Load(),
+		CacheHits:	this.metrics.cacheHits.Load(),
+		CacheMisses:	this.metrics.cacheMisses.Load(),
+		Size:		size,
+	}
+}
+
+var (
+	expvarOnce	sync.Once
+	expvarMap	*expvar.Map
+)
+
+// Publish the Metrics of "aof" to expvar, replacing the ones of a previous AOF of the same path .
+func publish(aof *AOF) {
+	expvarOnce.Do(func() {
+		expvarMap = expvar.NewMap(`aof`)
+	})
+	expvarMap.Set(aof.path, expvar.Func(func() any {
+		return aof.Metrics()
+	}))
+}
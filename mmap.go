@@ -0,0 +1,83 @@
+package aof
+
+import (
+	"io"
+	"os"
+)
+
+// The minimum size of the mapping of the active file .
+const mmapMinSize = 1 << 20
+
+// Map the active file again if it grew past the current mapping, the mapping is
+// made twice as large as needed so appends don't remap every time .
+// Failures aren't fatal, the reads just go to the file, the caller must hold the write lock .
+func (this *AOF) remap() {
+	file, ok := this.file.(*os.File)
+	if ! ok || ! this.opts.Mmap || this.size <= int64(len(this.mapping)) {
+		return
+	}
+	this.unmap()
+	length := int64(mmapMinSize)
+	for length < this.size * 2 {
+		length *= 2
+	}
+	if int64(int(length)) != length {
+		return
+	}
+	if mapping, err := mmapFile(file, int(length)); err == nil {
+		this.mapping = mapping
+	}
+}
+
+// Drop the mapping of the active file, the caller must hold the write lock .
+func (this *AOF) unmap() {
+	if this.mapping != nil {
+		munmapFile(this.mapping)
+		this.mapping = nil
+	}
+}
+
+// Return what reads of the segment file "file" should go through .
+// For the active file that's the mapping when there is one, the caller must hold the lock .
+func (this *AOF) source(file datafile) io.ReaderAt {
+	if file == this.file && this.mapping != nil {
+		return mapped{this}
+	}
+	return file
+}
+
+// Read the active file from its mapping, it takes the read lock per read
+// since the mapping may be replaced by appends .
+type mapped struct {
+	aof	*AOF
+}
+
+// Read the mapped bytes at "off", the part that isn't mapped is read from the file .
+func (m mapped) ReadAt(p []byte, off int64) (int, error) {
+	m.aof.RLock()
+	defer m.aof.RUnlock()
+	return m.aof.readAt(p, off)
+}
+
+// Read len(p) bytes at "off" of the active file bounded by our size,
+// the caller must hold the read lock .
+func (this *AOF) readAt(p []byte, off int64) (int, error) {
+	if this.closed {
+		return 0, ErrClosed
+	}
+	if off >= this.size {
+		return 0, io.EOF
+	}
+	var err error
+	if max := this.size - off; int64(len(p)) > max {
+		p, err = p[0:max], io.EOF
+	}
+	if off + int64(len(p)) <= int64(len(this.mapping)) {
+		return copy(p, this.mapping[off:]), err
+	}
+	n, e := this.file.ReadAt(p, off)
+	if e != nil {
+		err = e
+	}
+	return n, err
+}
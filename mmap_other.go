@@ -0,0 +1,18 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package aof
+
+import (
+	"errors"
+	"os"
+)
+
+// Memory mapping isn't supported on this platform, reads use the file instead .
+func mmapFile(f *os.File, length int) ([]byte, error) {
+	return nil, errors.New(`aof: mmap isn't supported on this platform`)
+}
+
+// Memory mapping isn't supported on this platform, so this is a no-op .
+func munmapFile(b []byte) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package aof
+
+import (
+	"os"
+	"syscall"
+)
+
+// Map "length" bytes of "f" for reading .
+func mmapFile(f *os.File, length int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, length, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// Unmap a mapping created by mmapFile .
+func munmapFile(b []byte) error {
+	return syscall.Munmap(b)
+}
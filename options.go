@@ -0,0 +1,186 @@
+package aof
+
+import (
+	"time"
+)
+
+// Options tunes the behavior of an AOF opened with OpenWithOptions .
+type Options struct {
+	// When to fsync the datafile after writes, defaults to SyncAlways .
+	Sync	SyncPolicy
+
+	// How PutBatch stores its items, defaults to FramingNone .
+	Framing	Framing
+
+	// Make every framed record start at a multiple of this many bytes of the datafile, for
+	// the direct I/O and the block aligned workloads, the framed writes are then preceded
+	// by a padding frame, whose payload is zero filled and which the framed scans skip .
+	// It costs up to Alignment + 4 bytes per write, a batch pads each of its records, and
+	// it doesn't apply to the unframed records which can't be told apart from a padding .
+	// The alignment is fixed when the datafile is created, opening an existing one with
+	// another alignment misaligns the records already written, till Compact or Vacuum realigns them .
+	// Zero or one disables the alignment .
+	Alignment	int
+
+	// Index the framed records at Open and keep the index updated by the framed
+	// writes (PutFramed, PutChecked), so records can be accessed by their sequence number using At .
+	// The index holds 24 bytes per record in memory, see IndexStride and IndexMemoryBytes .
+	BuildIndex	bool
+
+	// Keep a sparse index holding every IndexStride-th record only, so its memory is bounded
+	// by the record count divided by the stride, At then walks the frame headers following
+	// the nearest sampled record, at most IndexStride - 1 of them . Dropping segments and
+	// truncating the datafile rebuild a sparse index, since its samples are counted from the first record .
+	IndexStride	int
+
+	// Enable Delete, which appends a tombstone frame pointing to the deleted record .
+	// The tombstones of all the segments are collected at Open into an in memory set,
+	// which the live scans (ScanLive, ForEachLive) use to skip the deleted records,
+	// and Compact drops the deleted records of the active segment with their tombstones .
+	Tombstones	bool
+
+	// Write a header identifying the file as an AOF datafile, with its format version
+	// and how it's framed, compressed and encrypted, at the start of a new datafile
+	// (and of every segment) . Open then refuses a datafile without it with ErrBadMagic .
+	// A datafile that starts with the header is detected even if this isn't set,
+	// the records (and the offsets of the scans) start right after it .
+	Header	bool
+
+	// Receive the notable events, so they can be routed to any logging library:
+	// "slow_sync" (file, duration, error), "repair" (offset, discarded), "compact_start" (size),
+	// "compact_finish" (reclaimed, duration, error) and "rotate" (segment) . Every event
+	// carries the path of the datafile too, it's called synchronously and may hold our lock .
+	Logger	func(event string, fields map[string]any)
+
+	// The sync latency over which a "slow_sync" event is logged, defaults to 100ms .
+	SlowSync	time.Duration
+
+	// The size of the blocks read by the separated scans, forward and reverse, defaults to 32KB .
+	// It's raised to the length of the separator if it's shorter, see ScanBuffered to override it per scan .
+	ScanBufferSize	int
+
+	// Cache up to this many bytes of decoded records in memory, the least recently
+	// used ones are evicted first, so the hot records are served by Get, GetFramed and
+	// GetChecked without reading the datafile . Clear, Compact, TruncateAt and the
+	// other rewrites reset it, the hits and misses are counted by Metrics .
+	ReadCacheBytes	int
+
+	// Sync only the bytes written since the previous sync of Sync and of the sync
+	// policy using sync_file_range on Linux, which is cheaper than a full fsync . Beware
+	// that the file metadata isn't flushed, the grown file size included, and neither is
+	// the disk write cache, so after a power loss the appends may be lost even though they
+	// were synced, on the other platforms and the background flusher the full fsync is used .
+	UseSyncRange	bool
+
+	// Split the datafile into numbered segment files (name.000001, name.000002, ...)
+	// and start a new one once the active segment would exceed this size .
+	// Ids carry their segment number so Get reads from the right file, while the
+	// offset based methods (ReadAt, Scan, the framed API, ...) and Size refer to
+	// the active segment .
+	MaxSegmentBytes	int64
+
+	// Cap the total size of the segments, once an append goes over it the oldest
+	// segments are deleted till the total fits, so the log acts as a size capped
+	// buffer whose surviving ids stay valid . It requires MaxSegmentBytes, the
+	// retention works by whole segments and the active one is never dropped,
+	// so the total may exceed the cap by up to one segment .
+	MaxBytes	int64
+
+	// Compress the records written by Put, PutBytes and the framed writes,
+	// which stores them as compressed frames that Get and the framed reads
+	// decompress transparently, trading CPU on both paths for disk space .
+	// Uncompressed records may still be mixed in the same datafile .
+	Compression	Compression
+
+	// The gzip level from gzip.BestSpeed to gzip.BestCompression, 0 means gzip.DefaultCompression .
+	CompressionLevel	int
+
+	// Encrypt the records written by Put, PutBytes and the framed writes with
+	// AES-GCM, 32 bytes select AES-256 . Each record is sealed with a random nonce
+	// stored along the ciphertext in an encrypted frame, which Get and the framed
+	// reads decrypt transparently, returning ErrAuthFailed if it was tampered with .
+	// The ids and offsets refer to the stored frames, so their lengths are those of
+	// the ciphertexts rather than of the plaintexts .
+	EncryptionKey	[]byte
+
+	// Store the records written by Put, PutBytes, PutBatch and the framed writes in
+	// timestamped frames carrying the time they were written at, see ScanTimeRange .
+	Timestamps	bool
+
+	// Serve Get and ReadAt from a read only memory mapping of the active file,
+	// which is grown as appends go past it, the bytes that aren't mapped yet
+	// are read from the file . It's only supported on Linux, macOS and the BSDs,
+	// elsewhere it's ignored and the reads go to the file .
+	Mmap	bool
+
+	// Deduplicate the records by content: Put and PutBytes store checked frames and
+	// return the id of the live record already holding the same bytes instead of appending
+	// them again, so the AOF acts as a content addressable store . The SHA-256 of the
+	// payload of every framed record is collected at Open into an in memory map, which
+	// costs about 56 bytes per unique record, and the unframed records written
+	// otherwise aren't known to it, so it only makes sense with a framed datafile .
+	Dedup	bool
+
+	// Commit the concurrent writes in groups: Put, PutBytes and the framed writes
+	// queue their encoded record, up to this many, and a single committer goroutine
+	// writes all the queued records at once, synced once per the sync policy, so with
+	// SyncAlways many writers share a single fsync . Each write still blocks till its
+	// record is committed, and a group is all or nothing, like PutBatch . The writes
+	// queued when the AOF is closed return ErrClosed . The gain grows with the number of
+	// concurrent writers, see BenchmarkPutGroupCommit against BenchmarkPutLockPerWrite .
+	GroupCommit	int
+
+	// Give up waiting for a sync of the datafile, by Sync, by the writes with SyncAlways and by
+	// Close, after this long and return ErrTimeout, so a hung filesystem (a stale NFS mount
+	// for example) doesn't hold our lock forever . It's best effort, the sync can't be canceled
+	// so it keeps running in the background, and a write whose sync timed out is truncated
+	// away like any failed write . Zero means no timeout .
+	OpTimeout	time.Duration
+
+	// Publish the Metrics to expvar, under the "aof" map keyed by the datafile path .
+	PublishExpvar	bool
+}
+
+// SyncPolicy controls when appended data is flushed to stable storage .
+type SyncPolicy struct {
+	interval	time.Duration
+	never		bool
+}
+
+var (
+	// Fsync after every write, a successful Put is durable once it returns .
+	// This is the safest and the slowest policy .
+	SyncAlways = SyncPolicy{}
+
+	// Never fsync on our own, leave it to the OS (and to Close) .
+	// A crash or power loss may drop any write that the OS didn't flush yet .
+	SyncNever = SyncPolicy{never: true}
+)
+
+// Fsync in the background every "d", and once more on Close .
+// A crash may drop the writes of the last "d" at most .
+func SyncInterval(d time.Duration) SyncPolicy {
+	if d <= 0 {
+		return SyncAlways
+	}
+	return SyncPolicy{interval: d}
+}
+
+// Framing selects how the batch writes store records .
+type Framing int
+
+const (
+	// Store the raw bytes, like PutBytes .
+	FramingNone	Framing	= iota
+
+	// Store length prefixed frames, like PutFramed .
+	FramingLength
+
+	// Store checksummed frames, like PutChecked .
+	FramingChecked
+)
+
+// Whether writes must be synced before returning to the caller .
+func (p SyncPolicy) always() bool {
+	return ! p.never && p.interval <= 0
+}
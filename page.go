@@ -0,0 +1,46 @@
+package aof
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Returned, wrapped with the details, by Page when the cursor is malformed .
+var ErrBadCursor = errors.New(`aof: invalid cursor`)
+
+// Return the payloads of at most "limit" framed records starting at the opaque "cursor",
+// from the first one if it's empty, and the cursor of the next page, which is empty once
+// the end of the datafile was reached . The cursors are stateless, so a frontend can
+// page through a log over HTTP without seeing its offsets, and polling the last non empty
+// cursor again returns its page along with the records appended since then .
+func (this *AOF) Page(cursor string, limit int) (records [][]byte, nextCursor string, err error) {
+	if limit <= 0 {
+		return nil, ``, fmt.Errorf(`aof: page limit %d isn't positive`, limit)
+	}
+	var offset int64
+	if cursor != `` {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(raw) != 8 {
+			return nil, ``, fmt.Errorf(`%w %q`, ErrBadCursor, cursor)
+		}
+		if offset = int64(binary.BigEndian.Uint64(raw)); offset < 0 {
+			return nil, ``, fmt.Errorf(`%w %q`, ErrBadCursor, cursor)
+		}
+	}
+	records = [][]byte{}
+	next, err := this.WalkFrom(offset, limit, func(pos Position, data []byte) error {
+		records = append(records, data)
+		return nil
+	})
+	if err != nil {
+		return nil, ``, err
+	}
+	if len(records) < limit || next >= this.Size() {
+		return records, ``, nil
+	}
+	var raw [8]byte
+	binary.BigEndian.PutUint64(raw[:], uint64(next))
+	return records, base64.RawURLEncoding.EncodeToString(raw[:]), nil
+}
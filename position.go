@@ -0,0 +1,72 @@
+package aof
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The byte prefixing the hex encoded fields of an id carrying a segment number,
+// so the form of an id is known before its fields are split . The ids without
+// a prefix are the two fields form "offset:length", and the three fields form
+// of the ids written before the prefix existed .
+const segmentedID = 's'
+
+// Position is the typed form of a record id, the record occupies
+// the byte range [Offset, Offset+Length) of the datafile .
+// In segmented mode Segment is the number of the segment file holding it,
+// otherwise it's zero .
+type Position struct {
+	Offset	int64
+	Length	int64
+	Segment	int
+}
+
+// Return the string id of the position, the same one returned by Put .
+func (p Position) String() string {
+	if p.Segment > 0 {
+		return hex.EncodeToString([]byte(fmt.Sprintf(`%c%d:%d:%d`, segmentedID, p.Segment, p.Offset, p.Length)))
+	}
+	return hex.EncodeToString([]byte(fmt.Sprintf(`%d:%d`, p.Offset, p.Length)))
+}
+
+// Parse a string id returned by Put into its Position, either form .
+func ParsePosition(id string) (Position, error) {
+	var p Position
+	raw, err := hex.DecodeString(id)
+	if err != nil {
+		return p, fmt.Errorf(`%w %q: %v`, ErrBadID, id, err)
+	}
+	prefixed := len(raw) > 0 && raw[0] == segmentedID
+	if prefixed {
+		raw = raw[1:]
+	}
+	fields := strings.Split(string(raw), `:`)
+	if prefixed && len(fields) != 3 {
+		return p, fmt.Errorf(`%w %q: a segmented id has 3 fields`, ErrBadID, id)
+	}
+	if len(fields) < 2 {
+		return p, fmt.Errorf(`%w %q: missing ":" separator`, ErrBadID, id)
+	}
+	if len(fields) > 3 {
+		return p, fmt.Errorf(`%w %q: too many fields`, ErrBadID, id)
+	}
+	if len(fields) == 3 {
+		if p.Segment, err = strconv.Atoi(fields[0]); err != nil || p.Segment < 1 {
+			return Position{}, fmt.Errorf(`%w %q: bad segment %q`, ErrBadID, id, fields[0])
+		}
+		fields = fields[1:]
+	}
+	offset, length := fields[0], fields[1]
+	if p.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil {
+		return p, fmt.Errorf(`%w %q: bad offset: %v`, ErrBadID, id, err)
+	}
+	if p.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+		return p, fmt.Errorf(`%w %q: bad length: %v`, ErrBadID, id, err)
+	}
+	if p.Offset < 0 || p.Length < 0 {
+		return Position{}, fmt.Errorf(`%w %q: negative offset or length`, ErrBadID, id)
+	}
+	return p, nil
+}
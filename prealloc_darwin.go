@@ -0,0 +1,29 @@
+//go:build darwin
+
+package aof
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Reserve "length" bytes of "f" past its end without changing its size .
+// It tries a contiguous allocation first, then any allocation .
+func preallocate(f *os.File, offset, length int64) error {
+	fst := syscall.Fstore_t{
+		Flags:		syscall.F_ALLOCATECONTIG | syscall.F_ALLOCATEALL,
+		Posmode:	syscall.F_PEOFPOSMODE,
+		Length:		length,
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_PREALLOCATE, uintptr(unsafe.Pointer(&fst)))
+	if errno == 0 {
+		return nil
+	}
+	fst.Flags = syscall.F_ALLOCATEALL
+	_, _, errno = syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_PREALLOCATE, uintptr(unsafe.Pointer(&fst)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package aof
+
+import (
+	"os"
+	"syscall"
+)
+
+// Keep the file size unchanged while allocating, see fallocate(2) .
+const fallocKeepSize = 0x1
+
+// Reserve "length" bytes of "f" starting at "offset" without changing its size .
+func preallocate(f *os.File, offset, length int64) error {
+	return syscall.Fallocate(int(f.Fd()), fallocKeepSize, offset, length)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package aof
+
+import (
+	"os"
+)
+
+// Preallocation isn't supported on this platform, so this is a no-op .
+// Writing zeros past the end isn't an option since appends would go after them .
+func preallocate(f *os.File, offset, length int64) error {
+	return nil
+}
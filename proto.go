@@ -0,0 +1,73 @@
+//go:build protobuf
+
+package aof
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Append "m" in the varint length delimited encoding of protobuf (writeDelimitedTo,
+// protodelim), so the datafile can be read by the standard protobuf tooling and the streams
+// written by it can be scanned using ScanProto . The record is stored as is, neither framed
+// nor compressed nor encrypted, so it fails with Options.Compression, EncryptionKey or Timestamps,
+// and the returned Position covers the message without its prefix .
+// It's only built with the protobuf build tag, which keeps the core package dependency free .
+func (this *AOF) PutProto(m proto.Message) (Position, error) {
+	if err := this.plain(`PutProto`); err != nil {
+		return Position{}, err
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return Position{}, err
+	}
+	buf := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64 + len(data)), uint64(len(data)))
+	prefix := int64(len(buf))
+	p, err := this.putBytes(append(buf, data ...))
+	if err != nil {
+		return Position{}, err
+	}
+	p.Offset, p.Length = p.Offset + prefix, p.Length - prefix
+	this.notify(p)
+	return p, nil
+}
+
+// Scan the varint length delimited protobuf messages of the datafile, each is unmarshaled
+// into a message created by "new" and passed to "fn", iteration stops once it returns false .
+// A truncated or malformed message stops it with the error that was hit .
+// This function will hold the read lock till it ends .
+func (this *AOF) ScanProto(new func() proto.Message, fn func(m proto.Message) bool) error {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return ErrClosed
+	}
+	r := bufio.NewReaderSize(io.NewSectionReader(this.file, this.base, this.size - this.base), this.scanBuffer())
+	var prefix [binary.MaxVarintLen64]byte
+	for offset := this.base; offset < this.size; {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf(`aof: bad message length at %d: %w`, offset, err)
+		}
+		if length > uint64(this.size - offset) {
+			return fmt.Errorf(`aof: message at %d: %w`, offset, io.ErrUnexpectedEOF)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		m := new()
+		if err := proto.Unmarshal(data, m); err != nil {
+			return fmt.Errorf(`aof: message at %d: %w`, offset, err)
+		}
+		if ! fn(m) {
+			return nil
+		}
+		offset += int64(binary.PutUvarint(prefix[:], length)) + int64(length)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package aof
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Reader reads a datafile through its own descriptor, so any number of readers
+// can scan in parallel without waiting for the writer's lock nor blocking it .
+// It keeps reading the same file even if it gets replaced by Compact, and has a cursor
+// over the framed records to pull them one at a time using Next .
+type Reader struct {
+	file	*os.File
+	segment	int
+	base	int64
+	bufSize	int
+	cursor	int64
+	decode	func(flags byte, stored []byte) ([]byte, error)
+	section	func(src io.ReaderAt, file datafile, p Position) (*io.SectionReader, error)
+}
+
+// Open an independent Reader of the datafile, of the active segment in segmented mode .
+func (this *AOF) NewReader() (*Reader, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return nil, ErrClosed
+	}
+	file, err := os.Open(this.activePath())
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		file:		file,
+		segment:	this.segment,
+		base:		this.base,
+		bufSize:	this.scanBuffer(),
+		cursor:		this.base,
+		decode:		this.decodeRecord,
+		section:	this.sectionOf,
+	}, nil
+}
+
+// Return the current size of the file being read .
+func (this *Reader) size() (int64, error) {
+	finfo, err := this.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return finfo.Size(), nil
+}
+
+// Read the data of the pointer "id", decoded like AOF.Get does for the compressed
+// and the encrypted records .
+func (this *Reader) Get(id string) (*io.SectionReader, error) {
+	p, err := ParsePosition(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Segment != this.segment {
+		return nil, fmt.Errorf(`%w: id %q doesn't belong to the segment %d of the reader`, ErrOutOfRange, id, this.segment)
+	}
+	size, err := this.size()
+	if err != nil {
+		return nil, err
+	}
+	if p.Length > size || p.Offset > size - p.Length {
+		return nil, fmt.Errorf(`%w: id %q: %d:%d exceeds size %d`, ErrOutOfRange, id, p.Offset, p.Length, size)
+	}
+	return this.section(this.file, this.file, p)
+}
+
+// Read len(p) bytes starting at offset "off" of the file, so Reader is an io.ReaderAt .
+func (this *Reader) ReadAt(p []byte, off int64) (int, error) {
+	return this.file.ReadAt(p, off)
+}
+
+// Scan the file like AOF.ScanFrom, starting at the byte "offset" .
+// The data is passed raw like AOF.ScanFrom does, see Next for the decoded framed records .
+func (this *Reader) ScanFrom(offset int64, sep []byte, fn func(data []byte, atEOF bool) bool) error {
+	size, err := this.size()
+	if err != nil {
+		return err
+	}
+	if offset < this.base {
+		offset = this.base
+	}
+	if offset > size {
+		offset = size
+	}
+	err = scanSeparated(io.NewSectionReader(this.file, offset, size - offset), sep, this.bufSize, func(data []byte, atEOF bool) error {
+		if ! fn(data, atEOF) {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		return nil
+	}
+	return err
+}
+
+// Return the next framed record from the cursor, which starts at the first record, and move
+// the cursor past it, it returns io.EOF at the end of the file, which Next can be called again
+// after to get the records appended since then . The tombstones are skipped and the payloads
+// are decoded like the framed reads of AOF do .
+func (this *Reader) Next() ([]byte, error) {
+	size, err := this.size()
+	if err != nil {
+		return nil, err
+	}
+	frames := newFrameScanner(this.file, this.cursor, size)
+	f, data, err := frames.nextRecord()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = this.decode(f.flags, data); err != nil {
+		return nil, err
+	}
+	this.cursor = frames.offset
+	return data, nil
+}
+
+// Move the cursor to the framed record at "pos", so Next returns it .
+// It fails if "pos" isn't the payload of a frame of the file being read .
+func (this *Reader) SeekRecord(pos Position) error {
+	if pos.Segment != this.segment {
+		return fmt.Errorf(`%w: id %q doesn't belong to the segment %d of the reader`, ErrOutOfRange, pos.String(), this.segment)
+	}
+	size, err := this.size()
+	if err != nil {
+		return err
+	}
+	if pos.Length > size || pos.Offset > size - pos.Length {
+		return fmt.Errorf(`%w: %d:%d exceeds size %d`, ErrOutOfRange, pos.Offset, pos.Length, size)
+	}
+	// the header optional parts make its length vary, so each layout is tried
+	for _, layout := range headerLayouts {
+		for _, checked := range []byte{0, frameChecked} {
+			offset := pos.Offset - headerLength(checked | layout)
+			if offset < this.base {
+				continue
+			}
+			f, err := newFrameScanner(this.file, offset, size).skip()
+			if err == nil && f.payload() == pos.Offset && f.length == pos.Length {
+				this.cursor = offset
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf(`%w: no frame holds the record %d:%d`, ErrBadFrame, pos.Offset, pos.Length)
+}
+
+// Move the cursor back to the first record .
+func (this *Reader) Rewind() {
+	this.cursor = this.base
+}
+
+// Move the cursor to the end of the file, so Next only returns the records appended from now on .
+func (this *Reader) SeekEnd() error {
+	size, err := this.size()
+	if err != nil {
+		return err
+	}
+	this.cursor = size
+	return nil
+}
+
+// Close the Reader .
+func (this *Reader) Close() error {
+	return this.file.Close()
+}
@@ -0,0 +1,36 @@
+package aof
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestReaderGetDecodes(t *testing.T) {
+	for _, opts := range []Options{{Compression: GzipCompression}, {EncryptionKey: bytes.Repeat([]byte{1}, 32)}} {
+		a, err := OpenWithOptions(filepath.Join(t.TempDir(), `reader.aof`), 0644, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := bytes.Repeat([]byte(`record `), 70)
+		id, err := a.PutBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := a.NewReader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		section, err := r.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(section)
+		if err != nil || ! bytes.Equal(got, data) {
+			t.Fatalf(`got %d bytes and %v, expected %d bytes`, len(got), err, len(data))
+		}
+		r.Close()
+		a.Close()
+	}
+}
@@ -0,0 +1,153 @@
+package aof
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Whether the records are compressed, encrypted or timestamped when written,
+// which requires storing them as frames .
+func (this *AOF) transforms() bool {
+	return this.opts.Compression != NoCompression || this.aead != nil || this.opts.Timestamps
+}
+
+// Return an error if the records are transformed, for the writes of "method" which store
+// their records as is, so an encrypted datafile never gets a plaintext record .
+func (this *AOF) plain(method string) error {
+	if this.transforms() {
+		return fmt.Errorf(`aof: %s stores plain records, which Compression, EncryptionKey and Timestamps don't allow`, method)
+	}
+	return nil
+}
+
+// Transform "data" into the payload to store according to the options,
+// compressing then encrypting it . It returns the payload and the frame flags to store it with .
+func (this *AOF) encodeRecord(data []byte, flags byte) ([]byte, byte, error) {
+	var err error
+	if this.opts.Timestamps {
+		flags |= frameTimestamped
+	}
+	if this.opts.Compression == GzipCompression {
+		if data, err = this.compress(data); err != nil {
+			return nil, 0, err
+		}
+		flags |= frameCompressed | frameChecked
+	}
+	if this.aead != nil {
+		if data, err = this.encrypt(data); err != nil {
+			return nil, 0, err
+		}
+		flags |= frameEncrypted | frameChecked
+	}
+	return data, flags, nil
+}
+
+// Transform a stored payload back into the record data according to its frame flags .
+func (this *AOF) decodeRecord(flags byte, stored []byte) ([]byte, error) {
+	var err error
+	if flags & frameEncrypted != 0 {
+		if stored, err = this.decrypt(stored); err != nil {
+			return nil, err
+		}
+	}
+	if flags & frameCompressed != 0 {
+		return decompress(stored)
+	}
+	return stored, nil
+}
+
+// Return a reader of the record at "p" of "file", the caller must hold the read lock .
+// With compression or encryption enabled, a record that turns out to be the payload
+// of a compressed or encrypted frame is decoded, which its checked header right before it tells .
+func (this *AOF) section(file datafile, p Position) (*io.SectionReader, error) {
+	return this.sectionOf(this.source(file), file, p)
+}
+
+// Read the whole record at "p" of "file" while the caller holds the read lock,
+// so the active file is read directly rather than through the mapping .
+func (this *AOF) readRecord(file datafile, p Position) ([]byte, error) {
+	var src io.ReaderAt = file
+	if file == this.file {
+		src = readerAtFunc(this.readAt)
+	}
+	r, err := this.sectionOf(src, file, p)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, r.Size())
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// The optional parts of the checked frame headers, shortest header first .
+var headerLayouts = []byte{0, frameUserFlags, frameTimestamped, frameTimestamped | frameUserFlags}
+
+// Find the header of the checked frame whose payload is the record at "p" right before it,
+// each header layout is tried since their optional parts make their lengths differ .
+// It returns false if the record doesn't look like the payload of a checked frame .
+func lookback(read func(p []byte, off int64) (int, error), p Position) (frame, bool, error) {
+	n := headerLength(frameChecked | frameTimestamped | frameUserFlags)
+	if p.Offset < n {
+		n = p.Offset
+	}
+	if n < headerLength(frameChecked) {
+		return frame{}, false, nil
+	}
+	hdr := make([]byte, n)
+	if _, err := read(hdr, p.Offset - n); err != nil {
+		return frame{}, false, err
+	}
+	for _, layout := range headerLayouts {
+		length := headerLength(frameChecked | layout)
+		if length > n {
+			break
+		}
+		f, err := decodeFrame(bytes.NewReader(hdr[n-length:]), p.Offset - length, p.Offset + p.Length)
+		if err == nil && f.flags & (frameChecked | frameTimestamped | frameUserFlags) == frameChecked | layout && f.length == p.Length {
+			return f, true, nil
+		}
+	}
+	return frame{}, false, nil
+}
+
+// Adapt a read function to io.ReaderAt .
+type readerAtFunc func(p []byte, off int64) (int, error)
+
+func (f readerAtFunc) ReadAt(p []byte, off int64) (int, error) {
+	return f(p, off)
+}
+
+// Return a reader of the record at "p" of "file" reading the raw bytes from "src" .
+func (this *AOF) sectionOf(src io.ReaderAt, file datafile, p Position) (*io.SectionReader, error) {
+	encoded := this.opts.Compression != NoCompression || this.aead != nil
+	if ! encoded || p.Offset < frameHeaderSize + 4 {
+		return io.NewSectionReader(src, p.Offset, p.Length), nil
+	}
+	// the lock is already held, so read the active file directly rather than through "src"
+	read := file.ReadAt
+	if file == this.file {
+		read = this.readAt
+	}
+	f, ok, err := lookback(read, p)
+	if err != nil {
+		return nil, err
+	}
+	if ! ok || f.flags & (frameCompressed | frameEncrypted) == 0 {
+		return io.NewSectionReader(src, p.Offset, p.Length), nil
+	}
+	stored := make([]byte, p.Length)
+	if _, err := read(stored, p.Offset); err != nil {
+		return nil, err
+	}
+	if err := f.verify(stored); err != nil {
+		return nil, err
+	}
+	data, err := this.decodeRecord(f.flags, stored)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))), nil
+}
@@ -0,0 +1,68 @@
+package aof
+
+import (
+	"io"
+	"os"
+)
+
+// Append the new version of the record "oldID" read from "src" and return its id,
+// it's how a value is updated in an append-only store .
+// The log stays append-only so the old id remains readable until compaction reclaims it,
+// with Options.Tombstones the old record is deleted so Compact can reclaim it .
+// It fails without writing if "oldID" is malformed or points outside of the datafile .
+func (this *AOF) Replace(oldID string, src io.Reader) (string, error) {
+	if _, err := this.GetReader(oldID); err != nil {
+		return ``, err
+	}
+	id, err := this.Put(src)
+	if err != nil || ! this.opts.Tombstones {
+		return id, err
+	}
+	return id, this.Delete(oldID)
+}
+
+// Replace the whole contents of the datafile by the records read from "src", a log
+// regenerated in bulk for example . They're written to a temp file in the same directory,
+// synced and atomically renamed over the datafile, so the readers never see a partial state,
+// on failure the datafile is left as it was . The header, if any, is kept and "src" must not
+// start with it, like for ReadFrom . The Readers opened before keep reading the old file till
+// they're reopened, and the ids returned before don't point to valid records anymore .
+// In segmented mode the sealed segments are deleted once the active one is replaced .
+func (this *AOF) ReplaceAll(src io.Reader) error {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return err
+	}
+	tmp, err := this.createTemp(`replace`)
+	if err != nil {
+		return err
+	}
+	if this.base > 0 {
+		err = this.writeHeader(tmp)
+	}
+	if err == nil {
+		_, err = io.Copy(tmp, src)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := this.replaceWith(tmp); err != nil {
+		return err
+	}
+	if err := this.dropSegments(len(this.segments)); err != nil {
+		return err
+	}
+	if this.index != nil {
+		this.buildIndex()
+	}
+	if this.deleted != nil {
+		this.loadTombstones()
+	}
+	if this.dedup != nil {
+		this.loadDedup()
+	}
+	return nil
+}
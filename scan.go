@@ -0,0 +1,118 @@
+package aof
+
+import (
+	"bytes"
+	"io"
+)
+
+// The default size of the blocks read by the scanners, see Options.ScanBufferSize .
+const scanBufferSize = 32 << 10
+
+// Read "r" block by block and call "fn" for each record terminated by "sep" .
+// The separator is matched at any byte offset, even if it spans two blocks .
+// The trailing data that isn't terminated by "sep" is passed with atEOF = true .
+// Iteration stops once "fn" returns an error which is returned as is .
+func scanSeparated(r io.Reader, sep []byte, bufSize int, fn func(data []byte, atEOF bool) error) error {
+	if bufSize < len(sep) {
+		bufSize = len(sep)
+	}
+	block := make([]byte, bufSize)
+	var pending []byte
+	searched := 0
+	for {
+		n, err := r.Read(block)
+		if n > 0 {
+			pending = append(pending, block[0:n] ...)
+		}
+		for len(sep) > 0 {
+			from := searched - len(sep) + 1
+			if from < 0 {
+				from = 0
+			}
+			i := bytes.Index(pending[from:], sep)
+			if i < 0 {
+				searched = len(pending)
+				break
+			}
+			i += from
+			if e := fn(pending[0:i:i], false); e != nil {
+				return e
+			}
+			pending = pending[i+len(sep):]
+			if len(pending) == 0 {
+				pending = nil
+			}
+			searched = 0
+		}
+		if err == io.EOF {
+			if len(pending) > 0 {
+				return fn(pending, true)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Read "r" backwards block by block from "end" to "start" and call "fn" for each
+// record separated by "sep", newest first, the oldest one is passed with atEOF = true .
+// A separator of any length spanning blocks is still detected, and a separator ending the
+// data terminates the newest record rather than starting an empty one .
+// Iteration stops once "fn" returns an error which is returned as is .
+func reverseScanSeparated(r io.ReaderAt, start, end int64, sep []byte, bufSize int, fn func(data []byte, atEOF bool) error) error {
+	if bufSize < len(sep) {
+		bufSize = len(sep)
+	}
+	pos := end
+	first := true
+	// the pending bytes are buf[lo:hi] and each block is read right before them, buf is
+	// grown from the back once a block doesn't fit, so a record spanning many blocks isn't
+	// copied again for each of them, and the bytes passed to "fn" are never overwritten
+	var buf []byte
+	lo, hi := 0, 0
+	for pos > start {
+		n := int64(bufSize)
+		if pos - start < n {
+			n = pos - start
+		}
+		pos -= n
+		if int(n) > lo {
+			grown := make([]byte, 2 * (hi - lo) + int(n))
+			copy(grown[len(grown) - (hi - lo):], buf[lo:hi])
+			buf, lo, hi = grown, len(grown) - (hi - lo), len(grown)
+		}
+		lo -= int(n)
+		if _, err := r.ReadAt(buf[lo:lo + int(n)], pos); err != nil && err != io.EOF {
+			return err
+		}
+		pending := buf[lo:hi]
+		// a separator may start in the new block and end in the bytes carried over
+		// from the previous ones, so the search overlaps len(sep) - 1 of them
+		limit := int(n) + len(sep) - 1
+		for len(sep) > 0 {
+			if limit > len(pending) {
+				limit = len(pending)
+			}
+			i := bytes.LastIndex(pending[0:limit], sep)
+			if i < 0 {
+				break
+			}
+			data := pending[i+len(sep):len(pending):len(pending)]
+			if ! first || len(data) > 0 {
+				if err := fn(data, false); err != nil {
+					return err
+				}
+			}
+			first = false
+			pending = pending[0:i:i]
+			limit = i
+		}
+		hi = lo + len(pending)
+	}
+	if pos >= end && hi == lo {
+		return nil
+	}
+	return fn(buf[lo:hi:hi], true)
+}
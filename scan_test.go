@@ -0,0 +1,175 @@
+package aof
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Open an AOF in a temp dir holding "data" as is .
+func openWith(tb testing.TB, data []byte) *AOF {
+	a, err := Open(filepath.Join(tb.TempDir(), `scan.aof`), 0644)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		a.Close()
+	})
+	if _, err := a.PutBytes(data); err != nil {
+		tb.Fatal(err)
+	}
+	return a
+}
+
+// Records of varying lengths, none of them containing the byte 'x' the test separators are made of .
+func scanRecords() [][]byte {
+	var records [][]byte
+	for n := 0; n < 40; n++ {
+		record := make([]byte, n)
+		for i := range record {
+			record[i] = 'a' + byte((n + i) % 23)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// Join "records" each followed by "sep" .
+func joinRecords(records [][]byte, sep []byte) []byte {
+	var data []byte
+	for _, record := range records {
+		data = append(append(data, record ...), sep ...)
+	}
+	return data
+}
+
+func TestScanSeparators(t *testing.T) {
+	records := scanRecords()
+	for _, sep := range []string{`x`, `xx`, `xxx`} {
+		a := openWith(t, joinRecords(records, []byte(sep)))
+		// the blocks as small as the separator make most of them span two blocks
+		for _, bufSize := range []int{len(sep), 7, scanBufferSize} {
+			var got [][]byte
+			err := a.ScanBuffered(bufSize, []byte(sep), func(data []byte, atEOF bool) bool {
+				got = append(got, append([]byte{}, data ...))
+				return true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(records) {
+				t.Fatalf(`separator %q, blocks of %d: got %d records, expected %d`, sep, bufSize, len(got), len(records))
+			}
+			for i := range records {
+				if ! bytes.Equal(got[i], records[i]) {
+					t.Fatalf(`separator %q, blocks of %d: record %d is %q, expected %q`, sep, bufSize, i, got[i], records[i])
+				}
+			}
+		}
+	}
+}
+
+func TestReverseScanFourByteSeparator(t *testing.T) {
+	sep := []byte("\r\n\r\n")
+	// the lengths are odd so coprime with 4, the separators fall at every offset modulo 4
+	var records [][]byte
+	for n := 1; n < 60; n += 2 {
+		records = append(records, bytes.Repeat([]byte{'a' + byte(n % 26)}, n))
+	}
+	data := joinRecords(records, sep)
+	for _, bufSize := range []int{4, 5, 7, 64, scanBufferSize} {
+		a, err := OpenWithOptions(filepath.Join(t.TempDir(), `reverse.aof`), 0644, Options{ScanBufferSize: bufSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.PutBytes(data); err != nil {
+			t.Fatal(err)
+		}
+		var got [][]byte
+		a.ReverseScan(sep, func(data []byte, atEOF bool) bool {
+			got = append(got, data)
+			return true
+		})
+		// the oldest record is passed last with atEOF, the data starts right at it
+		if len(got) != len(records) {
+			t.Fatalf(`blocks of %d: got %d records, expected %d`, bufSize, len(got), len(records))
+		}
+		for i := range records {
+			if want := records[len(records) - 1 - i]; ! bytes.Equal(got[i], want) {
+				t.Fatalf(`blocks of %d: record %d is %q, expected %q`, bufSize, i, got[i], want)
+			}
+		}
+		a.Close()
+	}
+}
+
+func TestReverseScanRecordLargerThanBlocks(t *testing.T) {
+	large := bytes.Repeat([]byte(`0123456789`), 3 * scanBufferSize / 10)
+	a := openWith(t, append(append([]byte("small\n"), large ...), "\nlast"...))
+	var got [][]byte
+	a.ReverseScan([]byte("\n"), func(data []byte, atEOF bool) bool {
+		got = append(got, data)
+		return true
+	})
+	if len(got) != 3 || string(got[0]) != `last` || ! bytes.Equal(got[1], large) || string(got[2]) != `small` {
+		t.Fatalf(`got %d records`, len(got))
+	}
+}
+
+func benchmarkScan(b *testing.B, data []byte, reverse bool) {
+	a := openWith(b, data)
+	sep := []byte("\n")
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn := func(data []byte, atEOF bool) bool {
+			return true
+		}
+		if reverse {
+			a.ReverseScan(sep, fn)
+		} else {
+			a.Scan(sep, fn)
+		}
+	}
+}
+
+// The small records are within the blocks, the single record without a separator spans them all .
+func benchSmallRecords() []byte {
+	return bytes.Repeat([]byte("a small record of the log\n"), 1 << 20 / 26)
+}
+
+func benchLargeRecord() []byte {
+	return bytes.Repeat([]byte{'x'}, 32 << 20)
+}
+
+func BenchmarkScanSmallRecords(b *testing.B) {
+	benchmarkScan(b, benchSmallRecords(), false)
+}
+
+func BenchmarkReverseScanSmallRecords(b *testing.B) {
+	benchmarkScan(b, benchSmallRecords(), true)
+}
+
+func BenchmarkScanLargeRecord(b *testing.B) {
+	benchmarkScan(b, benchLargeRecord(), false)
+}
+
+func BenchmarkReverseScanLargeRecord(b *testing.B) {
+	benchmarkScan(b, benchLargeRecord(), true)
+}
+
+func BenchmarkScanBufferSizes(b *testing.B) {
+	a := openWith(b, benchSmallRecords())
+	sep := []byte("\n")
+	for _, size := range []int{512, 4 << 10, scanBufferSize, 256 << 10, 1 << 20} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.SetBytes(a.Size())
+			for i := 0; i < b.N; i++ {
+				a.ScanBuffered(size, sep, func(data []byte, atEOF bool) bool {
+					return true
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package aof
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A sealed segment file, read only from now on .
+type segment struct {
+	num	int
+	file	*os.File
+	size	int64
+}
+
+// Return the path of the segment number "n" of the datafile "filename" .
+func segmentPath(filename string, n int) string {
+	return fmt.Sprintf(`%s.%06d`, filename, n)
+}
+
+// Open the existing segments of our datafile, the newest one becomes the active one .
+func (this *AOF) openSegments(mode os.FileMode) (*os.File, error) {
+	matches, err := filepath.Glob(this.path + `.*`)
+	if err != nil {
+		return nil, err
+	}
+	nums := []int{}
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(match, this.path + `.`))
+		if err == nil && n > 0 {
+			nums = append(nums, n)
+		}
+	}
+	sort.Ints(nums)
+	if len(nums) == 0 {
+		nums = append(nums, 1)
+	}
+	for _, n := range nums[0:len(nums)-1] {
+		file, err := os.Open(segmentPath(this.path, n))
+		if err != nil {
+			return nil, err
+		}
+		finfo, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		this.segments = append(this.segments, segment{n, file, finfo.Size()})
+	}
+	this.segment = nums[len(nums)-1]
+	return os.OpenFile(segmentPath(this.path, this.segment), os.O_RDWR|os.O_APPEND|os.O_CREATE, mode)
+}
+
+// Return the Position of a record written to the active segment .
+func (this *AOF) position(offset, length int64) Position {
+	return Position{Offset: offset, Length: length, Segment: this.segment}
+}
+
+// Return the file and the size of the segment number "n", the caller must hold the lock .
+func (this *AOF) segmentFile(n int) (datafile, int64, error) {
+	if n == this.segment {
+		return this.file, this.size, nil
+	}
+	for _, seg := range this.segments {
+		if seg.num == n {
+			return seg.file, seg.size, nil
+		}
+	}
+	return nil, 0, fmt.Errorf(`%w: segment %d doesn't exist`, ErrOutOfRange, n)
+}
+
+// Start a new segment if writing "n" more bytes would make the active one
+// exceed Options.MaxSegmentBytes, the caller must hold the write lock .
+func (this *AOF) rotate(n int64) error {
+	if this.segment == 0 || this.size == 0 || this.size + n <= this.opts.MaxSegmentBytes {
+		return nil
+	}
+	if err := this.fsync(); err != nil {
+		return err
+	}
+	next, err := os.OpenFile(segmentPath(this.path, this.segment + 1), os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_EXCL, this.mode)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(next, true); err != nil {
+		next.Close()
+		return err
+	}
+	if this.base > 0 {
+		if err := this.writeHeader(next); err != nil {
+			unlockFile(next)
+			next.Close()
+			return err
+		}
+	}
+	// segments are only enabled by OpenWithOptions, so the active file is a real one
+	sealed := this.file.(*os.File)
+	unlockFile(sealed)
+	this.unmap()
+	this.segments = append(this.segments, segment{this.segment, sealed, this.size})
+	this.file, this.size = next, this.base
+	this.rangeSynced = this.size
+	this.segment++
+	if this.opts.Logger != nil {
+		this.log(`rotate`, map[string]any{`segment`: this.segment})
+	}
+	return nil
+}
+
+// Return the paths of the segment files, oldest first, the last one is the active segment .
+// It returns nil if the AOF isn't segmented .
+func (this *AOF) Segments() []string {
+	this.RLock()
+	defer this.RUnlock()
+	if this.segment == 0 {
+		return nil
+	}
+	paths := []string{}
+	for _, seg := range this.segments {
+		paths = append(paths, segmentPath(this.path, seg.num))
+	}
+	return append(paths, segmentPath(this.path, this.segment))
+}
+
+// Delete the oldest segment file, the ids pointing to it become invalid .
+// The active segment can't be dropped .
+func (this *AOF) DropOldest() error {
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return err
+	}
+	if len(this.segments) == 0 {
+		return fmt.Errorf(`aof: there is no sealed segment to drop`)
+	}
+	return this.dropSegments(1)
+}
+
+// Close and delete the "n" oldest sealed segments, the caller must hold the write lock .
+func (this *AOF) dropSegments(n int) error {
+	dropped := n > 0 && len(this.segments) > 0
+	if dropped {
+		this.cache.reset()
+	}
+	for n > 0 && len(this.segments) > 0 {
+		seg := this.segments[0]
+		seg.file.Close()
+		if err := os.Remove(segmentPath(this.path, seg.num)); err != nil {
+			return err
+		}
+		this.segments = this.segments[1:]
+		for len(this.index) > 0 && this.index[0].Segment == seg.num && this.indexStride() == 1 {
+			this.index, this.indexed = this.index[1:], this.indexed - 1
+		}
+		for p := range this.deleted {
+			if p.Segment == seg.num {
+				delete(this.deleted, p)
+			}
+		}
+		n--
+	}
+	// the samples of a sparse index are counted from the first record, which moved
+	if dropped && this.index != nil && this.indexStride() > 1 {
+		this.buildIndex()
+	}
+	return nil
+}
+
+// Drop the oldest segments while the total size exceeds Options.MaxBytes,
+// the caller must hold the write lock .
+func (this *AOF) trim() {
+	if this.opts.MaxBytes <= 0 {
+		return
+	}
+	total := this.size
+	for _, seg := range this.segments {
+		total += seg.size
+	}
+	for total > this.opts.MaxBytes && len(this.segments) > 0 {
+		total -= this.segments[0].size
+		if this.dropSegments(1) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package aof
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Copy the datafile as it is now, header included, into the new file "destPath"
+// and return a read only AOF over the copy, for consistent backups and read replicas .
+// The copy is bounded by the size at the time of the call, so the concurrent appends
+// are excluded, and it's synced before being opened . In segmented mode only
+// the active segment is copied .
+func (this *AOF) Snapshot(destPath string, mode os.FileMode) (*AOF, error) {
+	if err := this.snapshot(destPath, mode); err != nil {
+		return nil, err
+	}
+	return OpenReadOnly(destPath)
+}
+
+// Copy the datafile into "destPath" under the read lock .
+func (this *AOF) snapshot(destPath string, mode os.FileMode) error {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return ErrClosed
+	}
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dest, io.NewSectionReader(this.file, 0, this.size))
+	if err == nil {
+		err = dest.Sync()
+	}
+	if e := dest.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(destPath)
+		return err
+	}
+	syncDir(filepath.Dir(destPath))
+	return nil
+}
@@ -0,0 +1,41 @@
+package aof
+
+// Stats describes the state of an AOF .
+type Stats struct {
+	// The size we track and append at .
+	LogicalSize	int64
+
+	// The size of the datafile as reported by the filesystem,
+	// it differs from LogicalSize if the file was changed behind our back .
+	DiskSize	int64
+
+	// The number of framed records, -1 if the datafile isn't a sequence of frames .
+	RecordCount	int
+
+	// The path of the datafile .
+	Path	string
+}
+
+// Return the current Stats of the AOF .
+// It's cheap when the index is built, otherwise the frames are counted .
+func (this *AOF) Stats() (Stats, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if this.closed {
+		return Stats{}, ErrClosed
+	}
+	finfo, err := this.file.Stat()
+	if err != nil {
+		return Stats{}, err
+	}
+	count, err := this.count()
+	if err != nil {
+		count = -1
+	}
+	return Stats{
+		LogicalSize:	this.size,
+		DiskSize:	finfo.Size(),
+		RecordCount:	count,
+		Path:		this.path,
+	}, nil
+}
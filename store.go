@@ -0,0 +1,76 @@
+package aof
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Store is a typed layer over an AOF, values are encoded into checked frames .
+type Store[T any] struct {
+	aof	*AOF
+	encode	func(T) ([]byte, error)
+	decode	func([]byte, *T) error
+}
+
+// Create a Store of T values over "aof" using the specified encoder and decoder,
+// JSON is used for the ones that are nil .
+func NewStore[T any](aof *AOF, encode func(T) ([]byte, error), decode func([]byte, *T) error) *Store[T] {
+	if encode == nil {
+		encode = func(v T) ([]byte, error) {
+			return json.Marshal(v)
+		}
+	}
+	if decode == nil {
+		decode = func(data []byte, v *T) error {
+			return json.Unmarshal(data, v)
+		}
+	}
+	return &Store[T]{aof: aof, encode: encode, decode: decode}
+}
+
+// Encode and append "v", it returns the id of the record and error if any .
+func (this *Store[T]) Append(v T) (string, error) {
+	data, err := this.encode(v)
+	if err != nil {
+		return ``, err
+	}
+	_, p, err := this.aof.putFrame(data, frameChecked)
+	if err != nil {
+		return ``, err
+	}
+	return p.String(), nil
+}
+
+// Read and decode the value of the record "id" .
+func (this *Store[T]) Read(id string) (T, error) {
+	var v T
+	r, err := this.aof.GetReader(id)
+	if err != nil {
+		return v, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return v, err
+	}
+	err = this.decode(data, &v)
+	return v, err
+}
+
+// Decode every record in order and pass it to "fn" till it returns false .
+// It returns the first decode or read error .
+func (this *Store[T]) ForEach(fn func(T) bool) error {
+	err := this.aof.eachFrame(0, func(f frame, data []byte) error {
+		var v T
+		if err := this.decode(data, &v); err != nil {
+			return err
+		}
+		if ! fn(v) {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build linux && !arm
+
+package aof
+
+import (
+	"os"
+	"syscall"
+)
+
+// Wait for the pending writeback of the range, write it out and wait for it, see sync_file_range(2) .
+const syncFileRangeFlags = 0x1 | 0x2 | 0x4
+
+// Flush "length" bytes of "f" starting at "offset" without its metadata .
+func syncRange(f *os.File, offset, length int64) error {
+	return syscall.SyncFileRange(int(f.Fd()), offset, length, syncFileRangeFlags)
+}
@@ -0,0 +1,12 @@
+//go:build !linux || arm
+
+package aof
+
+import (
+	"os"
+)
+
+// There is no range sync outside of Linux (nor in the syscall package on 32 bit ARM), so the whole file is synced .
+func syncRange(f *os.File, offset, length int64) error {
+	return f.Sync()
+}
@@ -0,0 +1,30 @@
+package aof
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned when a file operation didn't complete within Options.OpTimeout .
+var ErrTimeout = errors.New(`aof: operation timed out`)
+
+// Run the blocking file operation "fn" and wait for it at most Options.OpTimeout,
+// then return ErrTimeout . It's best effort, the operation can't be canceled so
+// it keeps running in the background, "fn" must not touch our state for that reason .
+func (this *AOF) within(fn func() error) error {
+	if this.opts.OpTimeout <= 0 {
+		return fn()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	timer := time.NewTimer(this.opts.OpTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrTimeout
+	}
+}
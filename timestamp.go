@@ -0,0 +1,114 @@
+package aof
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Walk the timestamped framed records written between "from" and "to" included,
+// "fn" receives the time each one was written at and its payload .
+// The walk stops once it meets a record written after "to", since the records
+// are appended in time order, or once "fn" returns false . The records without
+// a timestamp are skipped .
+// This function will hold the read lock till it ends .
+func (this *AOF) ScanTimeRange(from, to time.Time, fn func(ts time.Time, data []byte) bool) {
+	start, end := from.UnixNano(), to.UnixNano()
+	this.eachFrame(0, func(f frame, data []byte) error {
+		if f.flags & frameTimestamped == 0 || f.ts < start {
+			return nil
+		}
+		if f.ts > end || ! fn(time.Unix(0, f.ts), data) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Walk the framed records like ForEachFramed, but pass the time each one was written at too,
+// which is the zero time for the records without a timestamp .
+// This function will hold the read lock till it ends .
+func (this *AOF) ForEachTimed(fn func(pos Position, ts time.Time, data []byte) bool) {
+	this.eachFrame(0, func(f frame, data []byte) error {
+		var ts time.Time
+		if f.flags & frameTimestamped != 0 {
+			ts = time.Unix(0, f.ts)
+		}
+		if ! fn(this.position(f.payload(), f.length), ts, data) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Rewrite the framed datafile dropping the records written more than "d" ago, it's a TTL
+// like retention for the timestamped logs . Since the records are appended in time order,
+// the frame headers are walked up to the first record written after the cutoff, and the
+// tail starting at it is copied to a temp file renamed over the datafile like Compact does .
+// It requires Options.Timestamps, and the walk stops at the first record without a timestamp
+// too, written before they were enabled for example, since its age is unknown .
+// It returns the number of removed records, a damaged datafile isn't pruned, see Repair .
+// In segmented mode only the active segment is pruned, see Options.MaxBytes for the others .
+func (this *AOF) PruneOlderThan(d time.Duration) (removed int, err error) {
+	cutoff := time.Now().Add(-d).UnixNano()
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return 0, err
+	}
+	if ! this.opts.Timestamps {
+		return 0, fmt.Errorf(`aof: pruning by age requires Options.Timestamps`)
+	}
+	frames := this.frames(0)
+	offset, pruned := frames.offset, false
+	for {
+		f, err := frames.skip()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		record := f.flags & (frameTombstone | framePadding) == 0
+		if f.flags & frameTimestamped != 0 && f.ts >= cutoff || record && f.flags & frameTimestamped == 0 {
+			break
+		}
+		if record {
+			removed++
+		}
+		offset, pruned = f.end(), pruned || f.flags & framePadding == 0
+	}
+	if ! pruned {
+		return 0, nil
+	}
+	tmp, err := this.createTemp(`prune`)
+	if err != nil {
+		return 0, err
+	}
+	if this.base > 0 {
+		err = this.writeHeader(tmp)
+	}
+	if err == nil {
+		// the tail starts aligned, so aligning its new offset keeps its frames aligned
+		_, err = tmp.Write(this.padding(this.base))
+	}
+	if err == nil {
+		_, err = io.Copy(tmp, io.NewSectionReader(readerAtFunc(this.readAt), offset, this.size - offset))
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := this.replaceWith(tmp); err != nil {
+		return 0, err
+	}
+	if this.index != nil {
+		this.buildIndex()
+	}
+	if this.deleted != nil {
+		this.loadTombstones()
+	}
+	return removed, nil
+}
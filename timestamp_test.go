@@ -0,0 +1,70 @@
+package aof
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Append a checked frame timestamped "ts" holding "data" .
+func putTimed(t *testing.T, a *AOF, data string, ts time.Time) {
+	buf, err := encodeFrame([]byte(data), frame{flags: frameChecked | frameTimestamped, ts: ts.UnixNano()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.appendFrame(buf, int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPruneOlderThan(t *testing.T) {
+	a, err := OpenWithOptions(filepath.Join(t.TempDir(), `prune.aof`), 0644, Options{Timestamps: true, BuildIndex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	for i := 0; i < 3; i++ {
+		putTimed(t, a, `old`, time.Now().Add(-time.Hour))
+	}
+	a.PutBytes([]byte(`new1`))
+	a.PutBytes([]byte(`new2`))
+	if removed, err := a.PruneOlderThan(time.Minute); err != nil || removed != 3 {
+		t.Fatalf(`removed %d records and got %v, expected 3 records`, removed, err)
+	}
+	if n, _ := a.Count(); n != 2 {
+		t.Fatalf(`%d records are left, expected 2`, n)
+	}
+	if removed, err := a.PruneOlderThan(time.Minute); err != nil || removed != 0 {
+		t.Fatalf(`removed %d records and got %v, expected none`, removed, err)
+	}
+}
+
+func TestPruneOlderThanWithoutTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `prune.aof`)
+	a, err := Open(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.PutFramed([]byte(`first`))
+	a.PutFramed([]byte(`second`))
+	size := a.Size()
+	if _, err := a.PruneOlderThan(time.Hour); err == nil {
+		t.Fatal(`pruning a log without timestamps should fail`)
+	}
+	if a.Size() != size {
+		t.Fatalf(`the size went from %d to %d`, size, a.Size())
+	}
+	a.Close()
+	// the records written before the timestamps were enabled are kept, their age is unknown
+	if a, err = OpenWithOptions(path, 0644, Options{Timestamps: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	putTimed(t, a, `old`, time.Now().Add(-time.Hour))
+	if removed, err := a.PruneOlderThan(time.Minute); err != nil || removed != 0 {
+		t.Fatalf(`removed %d records and got %v, expected none`, removed, err)
+	}
+	if a.Size() <= size {
+		t.Fatalf(`the size went from %d to %d`, size, a.Size())
+	}
+}
@@ -0,0 +1,134 @@
+package aof
+
+import (
+	"fmt"
+)
+
+// Append a tombstone marking the record "id" as deleted, it requires Options.Tombstones .
+// The record stays readable by its id, but the live scans skip it and Compact removes it .
+// Deleting an already deleted record is a no-op .
+func (this *AOF) Delete(id string) error {
+	if _, err := this.GetReader(id); err != nil {
+		return err
+	}
+	p, _ := ParsePosition(id)
+	this.Lock()
+	defer this.Unlock()
+	if err := this.writable(); err != nil {
+		return err
+	}
+	if this.deleted == nil {
+		return fmt.Errorf(`aof: tombstones aren't enabled`)
+	}
+	if _, ok := this.deleted[p]; ok {
+		return nil
+	}
+	buf, err := encodeFrame([]byte(p.String()), frame{flags: frameTombstone | frameChecked})
+	if err != nil {
+		return err
+	}
+	if _, err := this.writeFrames(buf); err != nil {
+		return err
+	}
+	this.deleted[p] = struct{}{}
+	return nil
+}
+
+// Whether the record "id" was deleted, it's always false without Options.Tombstones .
+func (this *AOF) Deleted(id string) bool {
+	p, err := ParsePosition(id)
+	if err != nil {
+		return false
+	}
+	this.RLock()
+	defer this.RUnlock()
+	_, ok := this.deleted[p]
+	return ok
+}
+
+// Walk the framed records like ScanFramed, skipping the deleted ones .
+// This function will hold the read lock till it ends .
+func (this *AOF) ScanLive(fn func(offset int64, data []byte) bool) {
+	this.eachFrame(0, func(f frame, data []byte) error {
+		if this.isDeleted(f) {
+			return nil
+		}
+		if ! fn(f.offset, data) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Walk the framed records like ForEachFramed, skipping the deleted ones .
+// This function will hold the read lock till it ends .
+func (this *AOF) ForEachLive(fn func(pos Position, data []byte) bool) {
+	this.eachFrame(0, func(f frame, data []byte) error {
+		if this.isDeleted(f) {
+			return nil
+		}
+		if ! fn(this.position(f.payload(), f.length), data) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// Whether the record of the frame "f" of the active segment was deleted,
+// the caller must hold the read lock .
+func (this *AOF) isDeleted(f frame) bool {
+	_, ok := this.deleted[this.position(f.payload(), f.length)]
+	return ok
+}
+
+// Collect the tombstones of every segment, the caller must hold the write lock .
+// The collection stops at the first damaged frame of a segment, like buildIndex does .
+func (this *AOF) loadTombstones() {
+	this.deleted = map[Position]struct{}{}
+	files := []datafile{}
+	sizes := []int64{}
+	for _, seg := range this.segments {
+		files, sizes = append(files, seg.file), append(sizes, seg.size)
+	}
+	files, sizes = append(files, this.file), append(sizes, this.size)
+	for i, file := range files {
+		base, _, err := readHeader(file, sizes[i])
+		if err != nil {
+			continue
+		}
+		frames := newFrameScanner(file, base, sizes[i])
+		for {
+			f, data, err := frames.next()
+			if err != nil {
+				break
+			}
+			if f.flags & frameTombstone == 0 {
+				continue
+			}
+			if p, err := ParsePosition(string(data)); err == nil {
+				this.deleted[p] = struct{}{}
+			}
+		}
+	}
+}
+
+// Read the next frame that isn't a tombstone nor a padding, it returns io.EOF after the last one .
+func (s *frameScanner) nextRecord() (frame, []byte, error) {
+	for {
+		f, data, err := s.next()
+		if err != nil || f.flags & (frameTombstone | framePadding) == 0 {
+			return f, data, err
+		}
+	}
+}
+
+// Skip the next frame that isn't a tombstone nor a padding, it returns io.EOF after the last one .
+func (s *frameScanner) skipRecord() (frame, error) {
+	for {
+		f, err := s.skip()
+		if err != nil || f.flags & (frameTombstone | framePadding) == 0 {
+			return f, err
+		}
+	}
+}
+